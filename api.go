@@ -7,7 +7,10 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -21,33 +24,177 @@ import (
 
 // API is used to direct HTTP requests to resources
 type API struct {
+	// Mux is nil if the API was created with a custom router
 	*goji.Mux
-	prefix    string
+	// router is used to register all routes and middleware of the API
+	router Router
+	prefix string
+	// Resources maps the path of each registered resource, "/prefix/type", to the resource
 	Resources map[string]*Resource
-	Debug     bool
+	// Debug enables the `GET /debug/routes` page and the `GET /debug/errors` report,
+	// it must be set using the WithDebug option
+	Debug bool
+	// MaxPageSize is the maximum page size of the paginated lists of the resources, 0 means
+	// no limit, see Resource.PagedList
+	MaxPageSize int
+	// BaseURL is the absolute URL the API is served from, used by ExportPostman
+	BaseURL string
+	// logger and middleware are registered once all options have been applied
+	logger     std.Logger
+	middleware []func(goji.Handler) goji.Handler
+	// responseMeta returns the top-level meta added to every response
+	responseMeta      ResponseMetaFunc
+	hasResponseMetaMW bool
+	// validateOnce ensures relationship targets are only validated on the first request in debug mode
+	validateOnce sync.Once
+	// logRoutesOnce ensures routes are only logged on the first request in debug mode
+	logRoutesOnce sync.Once
+	// added is the set of resources registered with Add
+	added map[*Resource]bool
+	// ResourceAddedHook is called after each resource registration, it must be set before calling Add
+	ResourceAddedHook func(resource *Resource)
+	// ActionAddedHook is called after each top-level action registration, it must be set before calling Action
+	ActionAddedHook func(action string)
+	// Version is the deployed version of the API, see SetVersion
+	Version string
+	// startedAt is the time the API was created at
+	startedAt time.Time
+	// server is used by ListenAndServe and ListenAndServeTLS, see SetServerConfig
+	server *http.Server
+	// readCache is set on each resource added, see EnableReadCache
+	readCache    store.Cache
+	readCacheTTL time.Duration
+	// allowedOrigins restricts cross-origin requests, see WithAllowedOrigins
+	allowedOrigins []string
 }
 
+// ResponseMetaFunc returns top-level meta to add to the response of a request.
+type ResponseMetaFunc func(ctx context.Context, r *http.Request) map[string]interface{}
+
+// contextKey is the type of the context keys defined by jshapi.
+type contextKey int
+
+const (
+	// responseMetaKey holds the ResponseMetaFunc of the API handling the request
+	responseMetaKey contextKey = iota
+	// permissionsKey holds the Permissions of the requesting user
+	permissionsKey
+	// ActorContextKey holds the principal making the request, see PrincipalFromContext
+	ActorContextKey
+	// PaginationContextKey holds the PageParams of list requests, see PaginationFromContext
+	PaginationContextKey
+	// FilterContextKey holds the store.FilterMap of list requests, see FilterFromContext
+	FilterContextKey
+	// SortContextKey holds the sort fields of list requests, see SortFromContext
+	SortContextKey
+	// FieldSetContextKey holds the sparse fieldset of list requests, see FieldSetFromContext
+	FieldSetContextKey
+	// TenantContextKey holds the tenant of the request, see TenantFromContext
+	TenantContextKey
+	// RequestIDContextKey holds the ID of the request, see RequestIDFromContext
+	RequestIDContextKey
+	// includedKey holds the related objects to include in a list response, see Resource.ListWithIncludes
+	includedKey
+)
+
+// Logger is used to log errors that cannot be sent as part of a response.
+var Logger std.Logger = log.New(os.Stderr, "jshapi: ", log.LstdFlags)
+
 /*
 SendHandler allows the customization of how API responses are sent and logged. This
 is used by all jshapi.Resource objects.
 */
-var SendHandler = DefaultSender(log.New(os.Stderr, "jshapi: ", log.LstdFlags))
+var SendHandler = DefaultSender(Logger)
+
+/*
+New initializes a new top level API Resource. Without any options, no additional
+setup is performed, the prefix is not validated, see MustNew:
+
+	api := jshapi.New("<prefix>",
+		jshapi.WithLogger(logger),
+		jshapi.WithDebug(),
+		jshapi.WithMiddleware(yourMiddleware),
+	)
+*/
+func New(prefix string, opts ...APIOption) *API {
+	mux := goji.NewMux()
+	api := NewAPIWithRouter(prefix, mux, opts...)
+	api.Mux = mux
+	return api
+}
+
+// MustNew initializes a new top level API like New, after checking the prefix with
+// ValidatePrefix. It panics if the prefix is invalid.
+func MustNew(prefix string, opts ...APIOption) *API {
+	if err := ValidatePrefix(prefix); err != nil {
+		panic(err)
+	}
+	return New(prefix, opts...)
+}
+
+// ValidatePrefix returns an error if the prefix cannot be used as an API path prefix,
+// which is the case of prefixes containing query string or fragment characters, `?` and
+// `#`, and of absolute URLs.
+func ValidatePrefix(prefix string) error {
+	lower := strings.ToLower(prefix)
+	switch {
+	case strings.ContainsAny(prefix, "?#"):
+		return fmt.Errorf("jshapi: invalid API prefix '%s', it must not contain '?' or '#'", prefix)
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		return fmt.Errorf("jshapi: invalid API prefix '%s', it must be a path, not an absolute URL", prefix)
+	}
+	return nil
+}
 
 /*
-New initializes a new top level API Resource without doing any additional setup.
+NewAPIWithRouter initializes a new top level API using a custom router instead of a
+goji.Mux. The API does not embed a goji.Mux in that case: requests served by the API
+are dispatched to the router, which must implement http.Handler or goji.Handler, and
+the promoted goji.Mux methods must not be called.
 */
-func New(prefix string) *API {
+func NewAPIWithRouter(prefix string, router Router, opts ...APIOption) *API {
 	// ensure that our top level prefix is "/" prefixed
 	if !strings.HasPrefix(prefix, "/") {
 		prefix = fmt.Sprintf("/%s", prefix)
 	}
 
 	// create our new API
-	return &API{
-		Mux:       goji.NewMux(),
+	api := &API{
+		router:    router,
 		prefix:    prefix,
 		Resources: map[string]*Resource{},
+		added:     map[*Resource]bool{},
+		startedAt: time.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	// the logger middleware must come first so that it wraps any other middleware
+	if api.logger != nil {
+		Logger = api.logger
+		SendHandler = DefaultSender(api.logger)
+		gojilogger := gojilogger.New(api.logger, api.Debug)
+		api.router.UseC(gojilogger.Middleware)
+	}
+	if len(api.allowedOrigins) > 0 {
+		api.router.UseC(api.allowedOriginsMiddleware)
 	}
+	for _, middleware := range api.middleware {
+		api.router.UseC(middleware)
+	}
+
+	// browsers request a favicon when hitting the API, keep it out of the 404s
+	api.router.HandleC(pat.Get("/favicon.ico"), goji.HandlerFunc(faviconHandler))
+
+	if api.Debug {
+		api.mountDebugRoutes()
+		api.router.UseC(api.validateRelationshipsMiddleware)
+		api.router.UseC(api.logRoutesMiddleware)
+	}
+
+	return api
 }
 
 /*
@@ -67,44 +214,146 @@ The most basic implementation is:
 
 */
 func Default(prefix string, debug bool, logger std.Logger) *API {
-	api := New(prefix)
-	SendHandler = DefaultSender(logger)
+	opts := []APIOption{WithLogger(logger)}
+	if debug {
+		opts = append(opts, WithDebug())
+	}
+	return New(prefix, opts...)
+}
 
-	// register logger middleware
-	gojilogger := gojilogger.New(logger, debug)
-	api.UseC(gojilogger.Middleware)
+// SetVersion sets the deployed version of the API, reported by its health check.
+func (a *API) SetVersion(version string) {
+	a.Version = version
+}
 
-	return api
+// Uptime returns the time elapsed since the API was created, reported by its health check.
+func (a *API) Uptime() time.Duration {
+	return time.Since(a.startedAt)
 }
 
 // Add implements mux support for a given resource which is effectively handled as:
 // pat.New("/(prefix/)resource.Plu*)
+// Adding the same resource twice has no effect, a warning is logged in debug mode.
 func (a *API) Add(resource *Resource) {
+	if a.added[resource] {
+		if a.Debug {
+			Logger.Printf("Resource '%s' has already been added to the API, skipping\n", resource.Type)
+		}
+		return
+	}
+	a.added[resource] = true
+	a.AddAt(a.prefix, resource)
+}
+
+// AddAt registers a resource under a custom prefix regardless of the API prefix:
+// pat.New("/prefix/resource.Plu*)
+// The same resource can be added at multiple prefixes, which allows different
+// middleware layers to be used for the same handlers.
+func (a *API) AddAt(prefix string, resource *Resource) {
+	// ensure that the prefix is "/" prefixed
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = fmt.Sprintf("/%s", prefix)
+	}
+
 	// track our associated resources, will enable auto-generation docs later
-	a.Resources[resource.Type] = resource
+	matcher := path.Join(prefix, resource.Type)
+	a.Resources[matcher] = resource
+	resource.debug = a.Debug
+	resource.maxPageSize = a.MaxPageSize
+	resource.logRouteOrder()
+	if a.Debug {
+		resource.trackErrors()
+	}
+	if a.readCache != nil {
+		resource.setReadCache(a.readCache, a.readCacheTTL)
+	}
 
 	// Because of how prefix matches work:
 	// https://godoc.org/github.com/goji/goji/pat#hdr-Prefix_Matches
 	// We need two separate routes,
-	// /(prefix/)resources
-	matcher := path.Join(a.prefix, resource.Type)
-	a.Mux.HandleC(pat.New(matcher), resource)
+	// /prefix/resources
+	a.router.HandleC(pat.New(matcher), resource)
 
 	// And:
-	// /(prefix/)resources/*
-	idMatcher := path.Join(a.prefix, resource.Type, "*")
-	a.Mux.HandleC(pat.New(idMatcher), resource)
+	// /prefix/resources/*
+	idMatcher := path.Join(prefix, resource.Type, "*")
+	a.router.HandleC(pat.New(idMatcher), resource)
+
+	if a.ResourceAddedHook != nil {
+		a.ResourceAddedHook(resource)
+	}
+}
+
+/*
+Configure calls fn with the resource of the given type registered in the API, so that
+packages can configure a resource without holding a reference to it:
+
+	err := api.Configure("users", func(resource *jshapi.Resource) {
+		resource.SetCacheBustOnMutation(true)
+	})
+
+An error is returned if no resource of that type has been added. When resources of that
+type are added under several prefixes, the one with the first path in sort order is configured.
+*/
+func (a *API) Configure(resourceType string, fn func(*Resource)) error {
+	for _, matcher := range a.sortedResourcePaths() {
+		if resource := a.Resources[matcher]; resource.Type == resourceType {
+			fn(resource)
+			return nil
+		}
+	}
+	return fmt.Errorf("jshapi: no resource of type '%s' has been added to the API", resourceType)
 }
 
 func (a *API) Action(action string, storage store.Action) {
 	matcher := path.Join(a.prefix, action)
 
-	a.Mux.HandleFuncC(
+	a.router.HandleC(
 		pat.Post(matcher),
-		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			a.actionHandler(ctx, w, r, storage)
-		},
+		}),
 	)
+
+	if a.ActionAddedHook != nil {
+		a.ActionAddedHook(action)
+	}
+}
+
+// DeleteAction registers a `DELETE /<prefix>/<action>` handler for destructive operations
+// that are not tied to a resource, such as logging out or flushing a cache. It responds
+// with 204 when the storage returns no object, and with 200 and the object otherwise.
+func (a *API) DeleteAction(action string, storage store.Action) {
+	matcher := path.Join(a.prefix, action)
+
+	a.router.HandleC(
+		pat.Delete(matcher),
+		goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			a.deleteActionHandler(ctx, w, r, storage)
+		}),
+	)
+
+	if a.ActionAddedHook != nil {
+		a.ActionAddedHook(action)
+	}
+}
+
+// DELETE /<action>
+func (a *API) deleteActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Action) {
+	response, err := storage(ctx, w, r)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	SendHandler(ctx, w, r, response)
 }
 
 // POST /<action>
@@ -122,13 +371,139 @@ func (a *API) actionHandler(ctx context.Context, w http.ResponseWriter, r *http.
 	SendHandler(ctx, w, r, response)
 }
 
+/*
+SetResponseMeta registers a function whose result is merged into the top-level meta of
+every response sent by the API, which is useful for API versions, server timestamps or
+request IDs:
+
+	api.SetResponseMeta(func(ctx context.Context, r *http.Request) map[string]interface{} {
+		return map[string]interface{}{"version": "1.2.0"}
+	})
+
+Setting a nil function disables it. The meta is added by the DefaultSender, custom
+SendHandlers are responsible for adding it themselves.
+*/
+func (a *API) SetResponseMeta(metaFunc ResponseMetaFunc) {
+	a.responseMeta = metaFunc
+	if !a.hasResponseMetaMW {
+		a.router.UseC(a.responseMetaMiddleware)
+		a.hasResponseMetaMW = true
+	}
+}
+
+// responseMetaMiddleware makes the response meta function available to the SendHandler.
+func (a *API) responseMetaMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if a.responseMeta != nil {
+			ctx = context.WithValue(ctx, responseMetaKey, a.responseMeta)
+		}
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// GET /favicon.ico
+func faviconHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+ValidateRelationshipTargets checks that the name of each relationship of the API resources
+matches the type of a resource registered in the API, or its plural `<name>s` for to-one
+relationships, and returns an error message for each dangling relationship. It should be
+called once all resources are added.
+
+In debug mode, this is done automatically on the first request and errors are logged.
+*/
+func (a *API) ValidateRelationshipTargets() []string {
+	types := map[string]bool{}
+	for _, resource := range a.Resources {
+		types[resource.Type] = true
+	}
+
+	var errors []string
+	seen := map[*Resource]bool{}
+	for _, matcher := range a.sortedResourcePaths() {
+		resource := a.Resources[matcher]
+		if seen[resource] {
+			continue
+		}
+		seen[resource] = true
+
+		for _, name := range sortedRelationships(resource) {
+			if types[name] || resource.Relationships[name] == ToOne && types[name+"s"] {
+				continue
+			}
+			errors = append(errors, fmt.Sprintf(
+				"Relationship '%s' of resource '%s' has no matching '%s' resource",
+				name, resource.Type, name,
+			))
+		}
+	}
+	return errors
+}
+
+// validateRelationshipsMiddleware logs dangling relationships on the first request.
+func (a *API) validateRelationshipsMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		a.validateOnce.Do(func() {
+			for _, err := range a.ValidateRelationshipTargets() {
+				Logger.Printf("%s\n", err)
+			}
+		})
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// LogRoutesTo logs the route tree of the API. It should be called once all resources have
+// been added. In debug mode, the routes are also logged on the first request.
+func (a *API) LogRoutesTo(logger std.Logger) {
+	logger.Printf("Registered routes:%s\n", a.RouteTree())
+}
+
+// logRoutesMiddleware logs the route tree of the API on the first request.
+func (a *API) logRoutesMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		a.logRoutesOnce.Do(func() {
+			a.LogRoutesTo(Logger)
+		})
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// sortedResourcePaths returns the paths the API resources are registered at, sorted.
+func (a *API) sortedResourcePaths() []string {
+	matchers := make([]string, 0, len(a.Resources))
+	for matcher := range a.Resources {
+		matchers = append(matchers, matcher)
+	}
+	sort.Strings(matchers)
+	return matchers
+}
+
+// sortedRelationships returns the relationship names of the resource, sorted.
+func sortedRelationships(resource *Resource) []string {
+	names := make([]string, 0, len(resource.Relationships))
+	for name := range resource.Relationships {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // RouteTree prints out all accepted routes for the API that use jshapi implemented
-// ways of adding routes through resources.
+// ways of adding routes through resources. Paths are aligned on the action label and
+// the longest method of the API.
 func (a *API) RouteTree() string {
 	var routes string
 
+	var all []*Resource
+	for _, resource := range a.Resources {
+		all = append(all, resource)
+	}
+	labelWidth, methodWidth := routeWidths(all...)
+
 	for _, resource := range a.Resources {
-		routes = strings.Join([]string{routes, resource.RouteTree()}, "")
+		routes = strings.Join([]string{routes, resource.routeTree(labelWidth, methodWidth)}, "")
 	}
 
 	return routes
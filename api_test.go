@@ -1,14 +1,26 @@
 package jshapi
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"goji.io"
 	"golang.org/x/net/context"
 
 	"github.com/EtixLabs/go-json-spec-handler"
 	"github.com/EtixLabs/go-json-spec-handler/client"
+	"github.com/EtixLabs/jsh-api/store"
+	"github.com/derekdowling/go-stdlogger"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -26,7 +38,7 @@ func TestAPI(t *testing.T) {
 		Convey("->AddResource()", func() {
 			resource := NewMockResource(testResourceType, 1, testObjAttrs)
 			api.Add(resource)
-			So(api.Resources[testResourceType], ShouldEqual, resource)
+			So(api.Resources["/api/"+testResourceType], ShouldEqual, resource)
 
 			Convey("should work with /<resource> routes", func() {
 				_, resp, err := jsc.List(baseURL, testResourceType)
@@ -45,6 +57,583 @@ func TestAPI(t *testing.T) {
 			})
 		})
 
+		Convey("->Add() twice", func() {
+			defer func(logger std.Logger, sender Sender) {
+				Logger, SendHandler = logger, sender
+			}(Logger, SendHandler)
+			var buf bytes.Buffer
+			api := New("api", WithDebug(), WithLogger(log.New(&buf, "", 0)))
+
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			api.Add(resource)
+			api.Add(resource)
+
+			So(api.Resources["/api/"+testResourceType], ShouldEqual, resource)
+			So(buf.String(), ShouldContainSubstring, "Resource 'bars' has already been added to the API, skipping")
+		})
+
+		Convey("->DefaultWithSlog()", func() {
+			defer func(logger std.Logger, sender Sender) {
+				Logger, SendHandler = logger, sender
+			}(Logger, SendHandler)
+
+			Convey("should serve requests", func() {
+				api := DefaultWithSlog("api", false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+				api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+				server := httptest.NewServer(api)
+				defer server.Close()
+
+				_, resp, err := jsc.List(server.URL+"/api", testResourceType)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should log to the slog logger", func() {
+				var buf bytes.Buffer
+				api := DefaultWithSlog("api", true, slog.New(slog.NewTextHandler(&buf, nil)))
+				resource := NewMockResource(testResourceType, 1, testObjAttrs)
+				api.Add(resource)
+				api.Add(resource)
+
+				So(buf.String(), ShouldContainSubstring, `level=INFO msg="Resource 'bars' has already been added to the API, skipping"`)
+			})
+		})
+
+		Convey("->ValidatePrefix()", func() {
+			So(ValidatePrefix("api/v1"), ShouldBeNil)
+			So(ValidatePrefix("/api"), ShouldBeNil)
+			So(ValidatePrefix("api?v=1"), ShouldNotBeNil)
+			So(ValidatePrefix("api#v1"), ShouldNotBeNil)
+			So(ValidatePrefix("https://example.com/api"), ShouldNotBeNil)
+			So(ValidatePrefix("HTTP://example.com/api"), ShouldNotBeNil)
+		})
+
+		Convey("->MustNew()", func() {
+			So(func() { MustNew("api") }, ShouldNotPanic)
+			So(func() { MustNew("api?v=1") }, ShouldPanic)
+		})
+
+		Convey("->Configure()", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			api.Add(resource)
+
+			Convey("should configure the resource of the type", func() {
+				var configured *Resource
+				err := api.Configure(testResourceType, func(res *Resource) {
+					configured = res
+				})
+
+				So(err, ShouldBeNil)
+				So(configured, ShouldEqual, resource)
+			})
+
+			Convey("should configure the first resource by path when added under several prefixes", func() {
+				api.AddAt("/v2", NewMockResource(testResourceType, 1, testObjAttrs))
+				for i := 0; i < 10; i++ {
+					var configured *Resource
+					err := api.Configure(testResourceType, func(res *Resource) {
+						configured = res
+					})
+
+					So(err, ShouldBeNil)
+					So(configured, ShouldEqual, resource)
+				}
+			})
+
+			Convey("should return an error for an unknown type", func() {
+				err := api.Configure("unknowns", func(res *Resource) {})
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("->WithAllowedOrigins()", func() {
+			api := New("api", WithAllowedOrigins("https://example.com", "*.example.org"))
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			get := func(origin string) *http.Response {
+				request, err := http.NewRequest("GET", server.URL+"/api/bars", nil)
+				So(err, ShouldBeNil)
+				request.Header.Set("Origin", origin)
+				resp, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				return resp
+			}
+
+			Convey("should allow an exact match", func() {
+				resp := get("https://example.com")
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(resp.Header.Get("Access-Control-Allow-Origin"), ShouldEqual, "https://example.com")
+			})
+
+			Convey("should allow a wildcard subdomain match", func() {
+				resp := get("http://app.example.org")
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(resp.Header.Get("Access-Control-Allow-Origin"), ShouldEqual, "http://app.example.org")
+			})
+
+			Convey("should reject other origins", func() {
+				for _, origin := range []string{"https://evil.com", "https://example.org", "https://app.example.com"} {
+					resp := get(origin)
+					So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+					So(resp.Header.Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+				}
+			})
+		})
+
+		Convey("->ReadOnlyMiddleware()", func() {
+			api := New("api", WithMiddleware(ReadOnlyMiddleware))
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+			server := httptest.NewServer(api)
+			baseURL := server.URL + api.prefix
+
+			Convey("should allow reads", func() {
+				_, resp, err := jsc.List(baseURL, testResourceType)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should reject writes", func() {
+				_, resp, err := jsc.Post(baseURL, sampleObject("", testResourceType, testObjAttrs))
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+				So(resp.Header.Get("Allow"), ShouldEqual, "GET,HEAD,OPTIONS")
+			})
+		})
+
+		Convey("->WriteOnlyMiddleware()", func() {
+			api := New("api", WithMiddleware(WriteOnlyMiddleware))
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+			server := httptest.NewServer(api)
+			baseURL := server.URL + api.prefix
+
+			_, resp, err := jsc.List(baseURL, testResourceType)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+			So(resp.Header.Get("Allow"), ShouldEqual, "POST,PATCH,DELETE")
+		})
+
+		Convey("->LogRoutesTo()", func() {
+			var buf bytes.Buffer
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+			api.LogRoutesTo(log.New(&buf, "", 0))
+
+			So(buf.String(), ShouldStartWith, "Registered routes:\n")
+			So(buf.String(), ShouldContainSubstring, "GET     - /bars/:id")
+		})
+
+		Convey("->Uptime() and ->SetVersion()", func() {
+			api.SetVersion("1.2.3")
+
+			So(api.Version, ShouldEqual, "1.2.3")
+			So(api.Uptime(), ShouldBeGreaterThan, 0)
+			So(api.Uptime(), ShouldBeLessThan, time.Minute)
+		})
+
+		Convey("->EnableReadCache()", func() {
+			var calls int
+			newCountingResource := func(resourceType string) *Resource {
+				resource := NewResource(resourceType)
+				resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+					calls++
+					return sampleObject(id, resourceType, testObjAttrs), nil
+				}, true)
+				resource.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+					return object, nil
+				}, true)
+				return resource
+			}
+
+			Convey("should cache resources added afterwards until a mutation", func() {
+				api.EnableReadCache(store.NewInMemoryCache(), time.Minute)
+				api.Add(newCountingResource("cacheds"))
+
+				_, _, err := jsc.Fetch(baseURL, "cacheds", "1")
+				So(err, ShouldBeNil)
+				doc, _, err := jsc.Fetch(baseURL, "cacheds", "1")
+				So(err, ShouldBeNil)
+				So(doc.Data[0].ID, ShouldEqual, "1")
+				So(calls, ShouldEqual, 1)
+
+				_, resp, err := jsc.Patch(baseURL, sampleObject("1", "cacheds", testObjAttrs))
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				_, _, err = jsc.Fetch(baseURL, "cacheds", "1")
+				So(err, ShouldBeNil)
+				So(calls, ShouldEqual, 2)
+			})
+
+			Convey("should not share cached objects between the object and its related object", func() {
+				api.EnableReadCache(store.NewInMemoryCache(), time.Minute)
+				resource := newCountingResource("posts")
+				resource.GetRelated(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+					return sampleObject("2", "authors", testObjAttrs), nil
+				}, "/:id/author", true)
+				api.Add(resource)
+
+				fetchRelated := func() *jsh.Document {
+					request, err := http.NewRequest("GET", baseURL+"/posts/1/author", nil)
+					So(err, ShouldBeNil)
+					doc, resp, err := jsc.Do(request, jsh.ObjectMode)
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+					return doc
+				}
+
+				doc, _, err := jsc.Fetch(baseURL, "posts", "1")
+				So(err, ShouldBeNil)
+				So(doc.Data[0].Type, ShouldEqual, "posts")
+				So(fetchRelated().Data[0].Type, ShouldEqual, "authors")
+
+				doc, _, err = jsc.Fetch(baseURL, "posts", "1")
+				So(err, ShouldBeNil)
+				So(doc.Data[0].Type, ShouldEqual, "posts")
+				So(calls, ShouldEqual, 1)
+				So(fetchRelated().Data[0].Type, ShouldEqual, "authors")
+			})
+
+			Convey("should not cache resources opting out", func() {
+				resource := newCountingResource("uncacheds")
+				resource.NoCaching()
+				api.Add(resource)
+				api.EnableReadCache(store.NewInMemoryCache(), time.Minute)
+
+				_, _, err := jsc.Fetch(baseURL, "uncacheds", "1")
+				So(err, ShouldBeNil)
+				_, _, err = jsc.Fetch(baseURL, "uncacheds", "1")
+				So(err, ShouldBeNil)
+				So(calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("->ListenAndServe()", func() {
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+			Convey("should be served by an http.Server", func() {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				So(err, ShouldBeNil)
+				server := &http.Server{Handler: api}
+				go server.Serve(listener)
+				defer server.Close()
+
+				_, resp, err := jsc.List("http://"+listener.Addr().String()+"/api", testResourceType)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should use the default timeouts", func() {
+				server := api.newServer(":8080")
+
+				So(server.Addr, ShouldEqual, ":8080")
+				So(server.Handler, ShouldEqual, api)
+				So(server.ReadTimeout, ShouldEqual, DefaultReadTimeout)
+				So(server.WriteTimeout, ShouldEqual, DefaultWriteTimeout)
+				So(server.IdleTimeout, ShouldEqual, DefaultIdleTimeout)
+			})
+
+			Convey("should use the server config", func() {
+				api.SetServerConfig(&http.Server{ReadTimeout: time.Minute})
+				server := api.newServer(":8080")
+
+				So(server.Handler, ShouldEqual, api)
+				So(server.ReadTimeout, ShouldEqual, time.Minute)
+				So(server.WriteTimeout, ShouldEqual, 0)
+			})
+		})
+
+		Convey("->AddAt()", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			api.Add(resource)
+			api.AddAt("internal", resource)
+			So(api.Resources["/api/"+testResourceType], ShouldEqual, resource)
+			So(api.Resources["/internal/"+testResourceType], ShouldEqual, resource)
+
+			Convey("should work with the custom prefix", func() {
+				_, resp, err := jsc.List(server.URL+"/internal", testResourceType)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("->NewAPIWithRouter()", func() {
+			mux := goji.NewMux()
+			api := NewAPIWithRouter("api", mux)
+			So(api.Mux, ShouldBeNil)
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			_, resp, err := jsc.List(server.URL+"/api", testResourceType)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			Convey("should serve requests through the API", func() {
+				server := httptest.NewServer(api)
+				defer server.Close()
+
+				_, resp, err := jsc.List(server.URL+"/api", testResourceType)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should fail for routers that cannot serve requests", func() {
+				api := NewAPIWithRouter("api", &registerOnlyRouter{})
+				w := httptest.NewRecorder()
+				api.ServeHTTP(w, httptest.NewRequest("GET", "/api/bars", nil))
+
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+
+		Convey("->SetResponseMeta()", func() {
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+			api.SetResponseMeta(func(ctx context.Context, r *http.Request) map[string]interface{} {
+				return map[string]interface{}{"version": "1.0"}
+			})
+
+			doc, resp, err := jsc.List(baseURL, testResourceType)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Meta, ShouldResemble, map[string]interface{}{"version": "1.0"})
+		})
+
+		Convey("->SetTopLevelMeta()", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			resource.SetTopLevelMeta("deprecated_since", func(ctx context.Context, r *http.Request) interface{} {
+				return "2.0"
+			})
+			resource.SetTopLevelMeta("warnings", func(ctx context.Context, r *http.Request) interface{} {
+				return nil
+			})
+			api.Add(resource)
+			api.SetResponseMeta(func(ctx context.Context, r *http.Request) map[string]interface{} {
+				return map[string]interface{}{"version": "1.0"}
+			})
+
+			doc, resp, err := jsc.List(baseURL, testResourceType)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Meta, ShouldResemble, map[string]interface{}{"version": "1.0", "deprecated_since": "2.0"})
+		})
+
+		Convey("->ValidateRelationshipTargets()", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			resource.ToMany("foos", &MockToManyStorage{ResourceType: "foos"})
+			api.Add(resource)
+
+			Convey("should report dangling relationships", func() {
+				errors := api.ValidateRelationshipTargets()
+				So(len(errors), ShouldEqual, 1)
+				So(errors[0], ShouldContainSubstring, "'foos'")
+			})
+
+			Convey("should accept registered relationships", func() {
+				api.Add(NewMockResource("foos", 1, testObjAttrs))
+				So(api.ValidateRelationshipTargets(), ShouldBeEmpty)
+			})
+
+			Convey("should report dangling to-one relationships", func() {
+				api.Add(NewMockResource("foos", 1, testObjAttrs))
+				resource.ToOne("author", &MockToOneStorage{ResourceType: "authors"})
+
+				errors := api.ValidateRelationshipTargets()
+				So(len(errors), ShouldEqual, 1)
+				So(errors[0], ShouldContainSubstring, "'author'")
+
+				api.Add(NewMockResource("authors", 1, testObjAttrs))
+				So(api.ValidateRelationshipTargets(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("->EnableTestMode()", func() {
+			resource := NewResource(testResourceType)
+			resource.SetTestData([]*jsh.Object{sampleObject("42", testResourceType, testObjAttrs)})
+			api.Add(resource)
+			api.EnableTestMode()
+
+			Convey("should serve the test data", func() {
+				doc, resp, err := jsc.List(baseURL, testResourceType)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(len(doc.Data), ShouldEqual, 1)
+				So(doc.Data[0].ID, ShouldEqual, "42")
+			})
+
+			Convey("should not find unknown objects", func() {
+				_, resp, _ := jsc.Fetch(baseURL, testResourceType, "1")
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("->New()", func() {
+
+			Convey("should apply options", func() {
+				api := New("api", WithDebug(), WithMaxPageSize(50), WithBaseURL("http://localhost"))
+				So(api.Debug, ShouldBeTrue)
+				So(api.MaxPageSize, ShouldEqual, 50)
+				So(api.BaseURL, ShouldEqual, "http://localhost")
+			})
+
+			Convey("should lower page sizes to the maximum page size", func() {
+				api := New("api", WithMaxPageSize(50))
+				var pagination store.Pagination
+				resource := NewResource(testResourceType)
+				resource.PagedList(func(ctx context.Context, p store.Pagination) (jsh.List, jsh.ErrorType) {
+					pagination = p
+					return jsh.List{}, nil
+				}, true)
+				api.Add(resource)
+				server := httptest.NewServer(api)
+				defer server.Close()
+
+				resp, err := http.Get(server.URL + "/api/bars?page[size]=100&page[limit]=20")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(pagination.Size, ShouldEqual, 50)
+				So(pagination.Limit, ShouldEqual, 20)
+			})
+		})
+
+		Convey("should respond to favicon requests", func() {
+			resp, err := http.Get(server.URL + "/favicon.ico")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+		})
+
+		Convey("->ServeHealth()", func() {
+			api.SetVersion("1.2.3")
+			api.ServeHealth("health")
+			health := func() (int, map[string]interface{}) {
+				resp, err := http.Get(baseURL + "/health")
+				So(err, ShouldBeNil)
+				defer resp.Body.Close()
+				body := map[string]interface{}{}
+				So(json.NewDecoder(resp.Body).Decode(&body), ShouldBeNil)
+				return resp.StatusCode, body["meta"].(map[string]interface{})
+			}
+
+			Convey("should report the uptime, start time and version", func() {
+				api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+				status, meta := health()
+				So(status, ShouldEqual, http.StatusOK)
+				So(meta["status"], ShouldEqual, "ok")
+				So(meta["version"], ShouldEqual, "1.2.3")
+				So(meta["started_at"], ShouldEqual, api.startedAt.UTC().Format(time.RFC3339))
+				_, err := time.ParseDuration(meta["uptime"].(string))
+				So(err, ShouldBeNil)
+			})
+
+			Convey("should report the storage failing to respond", func() {
+				api.Add(NewCRUDResource("unhealthies", &unhealthyStorage{&MockStorage{ResourceType: "unhealthies"}}))
+
+				status, meta := health()
+				So(status, ShouldEqual, http.StatusServiceUnavailable)
+				So(meta["status"], ShouldEqual, "unavailable")
+				So(meta["failures"], ShouldResemble, map[string]interface{}{"unhealthies": "connection refused"})
+			})
+		})
+
+		Convey("->ServePostman()", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			resource.SetTestData([]*jsh.Object{sampleObject("1", testResourceType, testObjAttrs)})
+			api.Add(resource)
+			api.ServePostman("postman.json")
+
+			resp, err := http.Get(baseURL + "/postman.json")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Disposition"), ShouldStartWith, "attachment")
+
+			collection := postmanCollection{}
+			So(json.NewDecoder(resp.Body).Decode(&collection), ShouldBeNil)
+			So(collection.Info.Schema, ShouldEqual, postmanSchema)
+			So(len(collection.Item), ShouldEqual, 5)
+
+			var patchItem postmanItem
+			for _, item := range collection.Item {
+				if item.Request.Method == "PATCH" {
+					patchItem = item
+				}
+			}
+			So(patchItem.Request.URL.Raw, ShouldEqual, "{{baseUrl}}/api/bars/:id")
+			So(patchItem.Request.URL.Variable, ShouldResemble, []postmanVariable{{Key: "id"}})
+			So(patchItem.Request.Header[0].Value, ShouldEqual, jsh.ContentType)
+			So(patchItem.Request.Body.Raw, ShouldContainSubstring, `"foo": "bar"`)
+		})
+
+		Convey("->mountDebugRoutes()", func() {
+
+			Convey("should serve the route tree in debug mode", func() {
+				api := New("api", WithDebug())
+				api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+				server := httptest.NewServer(api)
+				defer server.Close()
+
+				resp, err := http.Get(server.URL + "/api/debug/routes")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(resp.Header.Get("Content-Type"), ShouldStartWith, "text/html")
+
+				body, err := ioutil.ReadAll(resp.Body)
+				So(err, ShouldBeNil)
+				So(string(body), ShouldContainSubstring, `<a href="/api/bars" target="_blank">`)
+			})
+
+			Convey("should not be mounted otherwise", func() {
+				resp, err := http.Get(baseURL + "/debug/routes")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("->ErrorReport()", func() {
+			api := New("api", WithDebug())
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+"/api/bars", jsh.ContentType, bytes.NewBufferString("{"))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+			Convey("should count the error responses per route", func() {
+				So(api.ErrorReport(), ShouldResemble, map[string][2]int64{"POST /bars": {1, 0}})
+			})
+
+			Convey("should serve the report at /debug/errors", func() {
+				resp, err := http.Get(server.URL + "/api/debug/errors")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+				report := map[string][2]int64{}
+				So(json.NewDecoder(resp.Body).Decode(&report), ShouldBeNil)
+				So(report["POST /bars"], ShouldResemble, [2]int64{1, 0})
+			})
+
+			Convey("should reset the counts", func() {
+				api.ResetErrorCounts()
+				So(api.ErrorReport(), ShouldBeEmpty)
+			})
+
+			Convey("should count the requests of unmatched subpaths under a wildcard route", func() {
+				resp, err := http.Get(server.URL + "/api/bars/1/nope")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+
+				So(api.ErrorReport(), ShouldResemble, map[string][2]int64{
+					"POST /bars":  {1, 0},
+					"GET /bars/*": {1, 0},
+				})
+			})
+		})
+
 		Convey("->Action()", func() {
 			handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
 				object := sampleObject("", testResourceType, testObjAttrs)
@@ -59,5 +648,85 @@ func TestAPI(t *testing.T) {
 				So(doc.Data, ShouldNotBeEmpty)
 			})
 		})
+
+		Convey("->DeleteAction()", func() {
+
+			Convey("should respond with 204 when the storage returns no object", func() {
+				api.DeleteAction("session", func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+					return nil, nil
+				})
+				request, err := http.NewRequest("DELETE", baseURL+"/session", nil)
+				So(err, ShouldBeNil)
+				resp, err := http.DefaultClient.Do(request)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			})
+
+			Convey("should respond with 200 when the storage returns an object", func() {
+				api.DeleteAction("cache", func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+					return sampleObject("1", testResourceType, testObjAttrs), nil
+				})
+				request, err := http.NewRequest("DELETE", baseURL+"/cache", nil)
+				So(err, ShouldBeNil)
+				resp, err := http.DefaultClient.Do(request)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("->PrincipalMiddleware()", func() {
+			api := New("api", WithMiddleware(PrincipalMiddleware(func(r *http.Request) string {
+				return r.Header.Get("X-User")
+			})))
+			server := httptest.NewServer(api)
+
+			var principal string
+			api.Action("audit", func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+				principal, _ = PrincipalFromContext(ctx)
+				return sampleObject("1", testResourceType, testObjAttrs), nil
+			})
+
+			request, err := jsc.TopLevelActionRequest(server.URL+"/api", "audit", nil)
+			So(err, ShouldBeNil)
+			request.Header.Set("X-User", "alice")
+			_, response, err := jsc.Do(request, jsh.ObjectMode)
+
+			So(err, ShouldBeNil)
+			So(response.StatusCode, ShouldEqual, http.StatusOK)
+			So(principal, ShouldEqual, "alice")
+		})
+
+		Convey("->ResourceAddedHook and ->ActionAddedHook", func() {
+			var resources []*Resource
+			var actions []string
+			api.ResourceAddedHook = func(resource *Resource) { resources = append(resources, resource) }
+			api.ActionAddedHook = func(action string) { actions = append(actions, action) }
+
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			api.Add(resource)
+			api.Action("testAction", func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+				return nil, nil
+			})
+
+			So(resources, ShouldResemble, []*Resource{resource})
+			So(actions, ShouldResemble, []string{"testAction"})
+		})
 	})
 }
+
+// registerOnlyRouter is a Router that cannot serve requests.
+type registerOnlyRouter struct{}
+
+func (r *registerOnlyRouter) HandleC(pattern goji.Pattern, handler goji.Handler) {}
+func (r *registerOnlyRouter) UseC(middleware func(goji.Handler) goji.Handler)    {}
+
+// unhealthyStorage is a storage failing its health checks.
+type unhealthyStorage struct {
+	*MockStorage
+}
+
+func (s *unhealthyStorage) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
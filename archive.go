@@ -0,0 +1,56 @@
+package jshapi
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+/*
+WithArchive registers the routes of an archive lifecycle, separate from the CRUD delete:
+
+	DELETE /resources/:id/archive
+	POST   /resources/:id/unarchive
+	GET    /resources/archived
+*/
+func (res *Resource) WithArchive(storage store.Archiver) {
+	archiveMatcher := path.Join(patID, "archive")
+	res.HandleFuncC(pat.Delete(archiveMatcher), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		res.archiveHandler(ctx, w, r, storage.Archive)
+	})
+	res.addRoute(delete, archiveMatcher, true)
+
+	unarchiveMatcher := path.Join(patID, "unarchive")
+	res.HandleFuncC(pat.Post(unarchiveMatcher), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		res.archiveHandler(ctx, w, r, storage.Unarchive)
+	})
+	res.addRoute(post, unarchiveMatcher, true)
+
+	res.handleStatic(patArchived, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		res.listHandler(ctx, w, r, storage.ListArchived)
+	})
+	res.addRoute(head, patArchived, true)
+	res.addRoute(get, patArchived, true)
+}
+
+// DELETE /resources/:id/archive and POST /resources/:id/unarchive
+func (res *Resource) archiveHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Delete) {
+	id := pat.Param(ctx, "id")
+
+	start := time.Now()
+	err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	res.addCacheBustHeaders(w)
+	w.WriteHeader(http.StatusNoContent)
+}
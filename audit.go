@@ -0,0 +1,48 @@
+package jshapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// emitEvent sends an audit event for a successful mutation if the resource has an auditor.
+// The source of the event is the path of the resource collection, i.e. /<prefix>/<resources>.
+func (res *Resource) emitEvent(ctx context.Context, r *http.Request, eventType string, id string, data interface{}) {
+	if res.auditor == nil {
+		return
+	}
+
+	source := strings.TrimSuffix(r.URL.Path, "/")
+	if id != "" {
+		source = strings.TrimSuffix(source, "/"+id)
+	}
+
+	event := store.CloudEvent{
+		SpecVersion:     store.CloudEventsSpecVersion,
+		ID:              newEventID(),
+		Source:          source,
+		Type:            eventType,
+		DataContentType: jsh.ContentType,
+		Data:            data,
+	}
+	// the mutation already succeeded, a failing auditor must not change the response
+	if err := res.auditor.Emit(ctx, event); err != nil {
+		Logger.Printf("Error emitting %s event: %s\n", eventType, err.Error())
+	}
+}
+
+// newEventID generates a random event ID.
+func newEventID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
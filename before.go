@@ -0,0 +1,74 @@
+package jshapi
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// BeforeObjectFunc runs before an object is created or updated, it may return a modified
+// object, or nil to keep the object unchanged, and aborts the request with an error.
+type BeforeObjectFunc func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
+
+// BeforeIDFunc runs before an object is fetched or deleted, it aborts the request with an error.
+type BeforeIDFunc func(ctx context.Context, id string) jsh.ErrorType
+
+/*
+BeforeCreate registers a hook run by the POST /resources handler between the parsing of
+the object and the call to storage, e.g. to stamp the creator of the object:
+
+	resource.BeforeCreate(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		principal, _ := jshapi.PrincipalFromContext(ctx)
+		object.Meta = map[string]interface{}{"created_by": principal}
+		return object, nil
+	})
+
+Hooks run in registration order, the first error being sent without calling storage.
+*/
+func (res *Resource) BeforeCreate(hook BeforeObjectFunc) {
+	res.beforeCreate = append(res.beforeCreate, hook)
+}
+
+// BeforeUpdate registers a hook run by the PATCH /resources/:id handler before the call
+// to storage, see BeforeCreate.
+func (res *Resource) BeforeUpdate(hook BeforeObjectFunc) {
+	res.beforeUpdate = append(res.beforeUpdate, hook)
+}
+
+// BeforeFetch registers a hook run by the GET /resources/:id handler before the call to
+// storage. Hooks run in registration order, the first error being sent without calling storage.
+func (res *Resource) BeforeFetch(hook BeforeIDFunc) {
+	res.beforeFetch = append(res.beforeFetch, hook)
+}
+
+// BeforeDelete registers a hook run by the DELETE /resources/:id handler before the call
+// to storage, see BeforeFetch.
+func (res *Resource) BeforeDelete(hook BeforeIDFunc) {
+	res.beforeDelete = append(res.beforeDelete, hook)
+}
+
+// runBeforeObject runs the hooks in order, returning the object modified by the hooks.
+func runBeforeObject(ctx context.Context, object *jsh.Object, hooks []BeforeObjectFunc) (*jsh.Object, jsh.ErrorType) {
+	for _, hook := range hooks {
+		modified, err := hook(ctx, object)
+		if err != nil && reflect.ValueOf(err).IsNil() == false {
+			return nil, err
+		}
+		if modified != nil {
+			object = modified
+		}
+	}
+	return object, nil
+}
+
+// runBeforeID runs the hooks in order, returning the first error.
+func runBeforeID(ctx context.Context, id string, hooks []BeforeIDFunc) jsh.ErrorType {
+	for _, hook := range hooks {
+		if err := hook(ctx, id); err != nil && reflect.ValueOf(err).IsNil() == false {
+			return err
+		}
+	}
+	return nil
+}
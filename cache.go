@@ -0,0 +1,102 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"goji.io"
+	"goji.io/middleware"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+/*
+EnableReadCache wraps the Get and List storage of each resource of the API, including the
+resources added afterwards, with a store.CachedCRUD read-through cache keeping results
+during ttl. Successful mutations of a resource, including its relationships, invalidate
+the cached entries of its type. Resources can opt out using Resource.NoCaching.
+*/
+func (a *API) EnableReadCache(cache store.Cache, ttl time.Duration) {
+	a.readCache = cache
+	a.readCacheTTL = ttl
+	for _, resource := range a.Resources {
+		resource.setReadCache(cache, ttl)
+	}
+}
+
+// NoCaching opts the resource out of the read cache enabled by API.EnableReadCache.
+func (res *Resource) NoCaching() {
+	res.noCaching = true
+	res.readCache = nil
+}
+
+// setReadCache enables the read cache for the resource unless it opted out.
+func (res *Resource) setReadCache(cache store.Cache, ttl time.Duration) {
+	if res.noCaching {
+		return
+	}
+	if res.readCache == nil {
+		res.UseC(res.readCacheMiddleware)
+	}
+	res.readCache = &store.CachedCRUD{Type: res.Type, Cache: cache, TTL: ttl}
+}
+
+// cachedGet decorates the storage with the read cache of the resource, if enabled.
+// Sparse fieldset requests are not cached since the object may only have some fields,
+// nor related objects fetched by GetRelated since they would share the key of the object.
+func (res *Resource) cachedGet(ctx context.Context, storage store.Get) store.Get {
+	if _, sparse := store.ProjectionHintFromContext(ctx); res.readCache == nil || sparse {
+		return storage
+	}
+	if route, ok := middleware.Pattern(ctx).(fmt.Stringer); ok && route.String() != patID {
+		return storage
+	}
+	return res.readCache.Get(storage)
+}
+
+// cachedList decorates the storage with the read cache of the resource, if enabled.
+// Lists are cached by query parameters, except lists with included objects.
+func (res *Resource) cachedList(ctx context.Context, r *http.Request, storage store.List) store.List {
+	if _, includes := ctx.Value(includedKey).(map[string]jsh.List); res.readCache == nil || includes {
+		return storage
+	}
+	return res.readCache.List(r.URL.Path+"?"+r.URL.Query().Encode(), storage)
+}
+
+// readCacheMiddleware invalidates the read cache of the resource when a mutation succeeds.
+func (res *Resource) readCacheMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		cache := res.readCache
+		switch {
+		case cache == nil, r.Method == get, r.Method == head, r.Method == options:
+		default:
+			w = &invalidatingResponseWriter{ResponseWriter: w, cache: cache}
+		}
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// invalidatingResponseWriter invalidates a read cache before writing a successful status,
+// so that clients receiving the response cannot read stale objects afterwards.
+type invalidatingResponseWriter struct {
+	http.ResponseWriter
+	cache *store.CachedCRUD
+}
+
+// WriteHeader invalidates the cache if the status is not an error.
+func (w *invalidatingResponseWriter) WriteHeader(status int) {
+	if status < http.StatusBadRequest {
+		w.cache.Invalidate()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher if the wrapped response writer does.
+func (w *invalidatingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
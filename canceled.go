@@ -0,0 +1,26 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// StatusClientClosedRequest is the non-standard status responded when the client closed
+// the request before the resource called its storage.
+const StatusClientClosedRequest = 499
+
+// clientClosed responds with a 499 status and returns true when the request context is
+// already done, so that handlers can return before calling storage for a client that
+// disconnected. It is logged in debug mode only.
+func (res *Resource) clientClosed(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+
+	if res.debug {
+		Logger.Printf("%s %s: client closed request before storage call: %s\n", r.Method, r.URL.Path, ctx.Err())
+	}
+	w.WriteHeader(StatusClientClosedRequest)
+	return true
+}
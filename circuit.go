@@ -0,0 +1,183 @@
+package jshapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// Circuit breaker states reported by SimpleBreaker.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned by SimpleBreaker.Call when the circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker stops calling a failing storage until it recovers.
+// Call returns an error without calling fn when the circuit is open.
+type CircuitBreaker interface {
+	Call(fn func() error) error
+	State() string
+}
+
+/*
+SetCircuitBreaker wraps each request handled by the resource, and therefore its storage
+call, in the circuit breaker. Responses with a 5xx status are reported as failures.
+When the circuit is open, a 503 error with a Retry-After header is sent without calling
+storage. The Retry-After delay is the breaker timeout for a SimpleBreaker, rounded up to
+the second, and one second for other implementations.
+*/
+func (res *Resource) SetCircuitBreaker(cb CircuitBreaker) {
+	if res.circuitBreaker == nil {
+		res.UseC(res.circuitBreakerMiddleware)
+	}
+	res.circuitBreaker = cb
+}
+
+// circuitBreakerMiddleware calls the next handler through the circuit breaker of the resource.
+func (res *Resource) circuitBreakerMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		cb := res.circuitBreaker
+		if cb == nil {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+
+		called := false
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		cb.Call(func() error {
+			called = true
+			next.ServeHTTPC(ctx, sw, r)
+			if sw.status >= http.StatusInternalServerError {
+				return fmt.Errorf("%s %s responded with %d", r.Method, r.URL.Path, sw.status)
+			}
+			return nil
+		})
+		if called {
+			return
+		}
+
+		retryAfter := time.Second
+		if breaker, ok := cb.(*SimpleBreaker); ok {
+			retryAfter = breaker.Timeout
+		}
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+		SendHandler(ctx, w, r, &jsh.Error{
+			Title:  "Service Unavailable",
+			Detail: fmt.Sprintf("The storage of resource '%s' is unavailable", res.Type),
+			Status: http.StatusServiceUnavailable,
+		})
+	})
+}
+
+// statusResponseWriter records the status of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status before writing it.
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher if the wrapped response writer does.
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Push implements http.Pusher, returning http.ErrNotSupported if the wrapped response writer does not.
+func (w *statusResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the wrapped response writer.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// SimpleBreakerConfig configures a SimpleBreaker.
+type SimpleBreakerConfig struct {
+	// Threshold is the number of consecutive failures opening the circuit
+	Threshold int
+	// Timeout is how long the circuit stays open before a call is attempted again
+	Timeout time.Duration
+}
+
+/*
+SimpleBreaker is a CircuitBreaker opening the circuit after Threshold consecutive failures.
+Once Timeout has elapsed, the circuit is half-open: the next call is attempted as a single
+probe, closing the circuit if it succeeds and opening it again otherwise. Other calls fail
+with ErrCircuitOpen while the probe is running.
+*/
+type SimpleBreaker struct {
+	SimpleBreakerConfig
+	mutex    sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// NewSimpleBreaker creates a closed SimpleBreaker.
+func NewSimpleBreaker(config SimpleBreakerConfig) *SimpleBreaker {
+	return &SimpleBreaker{SimpleBreakerConfig: config, state: CircuitClosed}
+}
+
+// Call calls fn unless the circuit is open, and records its result.
+func (b *SimpleBreaker) Call(fn func() error) error {
+	b.mutex.Lock()
+	switch b.state {
+	case CircuitHalfOpen:
+		// a probe is already running
+		b.mutex.Unlock()
+		return ErrCircuitOpen
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.Timeout {
+			b.mutex.Unlock()
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+	}
+	b.mutex.Unlock()
+
+	err := fn()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err == nil {
+		b.state = CircuitClosed
+		b.failures = 0
+		return nil
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.Threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+	return err
+}
+
+// State returns the current state of the circuit: closed, open or half-open.
+func (b *SimpleBreaker) State() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
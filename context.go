@@ -0,0 +1,92 @@
+package jshapi
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// PageParams holds the `page[<param>]=<value>` query parameters of a list request, keyed by param.
+type PageParams map[string]string
+
+// PaginationFromContext returns the pagination parameters of the list request, if any.
+func PaginationFromContext(ctx context.Context) (PageParams, bool) {
+	params, ok := ctx.Value(PaginationContextKey).(PageParams)
+	return params, ok
+}
+
+// FilterFromContext returns the filters of the list request, if any.
+func FilterFromContext(ctx context.Context) (store.FilterMap, bool) {
+	filters, ok := ctx.Value(FilterContextKey).(store.FilterMap)
+	return filters, ok
+}
+
+// SortFromContext returns the sort fields of the list request, "-" prefixed when descending, if any.
+func SortFromContext(ctx context.Context) ([]string, bool) {
+	fields, ok := ctx.Value(SortContextKey).([]string)
+	return fields, ok
+}
+
+// FieldSetFromContext returns the sparse fieldset of the list request for the resource type, if any.
+func FieldSetFromContext(ctx context.Context) ([]string, bool) {
+	fields, ok := ctx.Value(FieldSetContextKey).([]string)
+	return fields, ok
+}
+
+// WithTenant returns a copy of the context holding the tenant of the request.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, TenantContextKey, tenant)
+}
+
+// TenantFromContext returns the tenant of the request, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(TenantContextKey).(string)
+	return tenant, ok
+}
+
+// WithRequestID returns a copy of the context holding the ID of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, RequestIDContextKey, id)
+}
+
+// RequestIDFromContext returns the ID of the request, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(RequestIDContextKey).(string)
+	return id, ok
+}
+
+// withListQuery returns a copy of the context holding the pagination, filters, sort fields
+// and sparse fieldset of the list request, for the ones present in the query.
+func withListQuery(ctx context.Context, query url.Values, resourceType string) context.Context {
+	params := PageParams{}
+	for key := range query {
+		if strings.HasPrefix(key, "page[") && strings.HasSuffix(key, "]") {
+			params[key[len("page["):len(key)-1]] = query.Get(key)
+		}
+	}
+	if len(params) > 0 {
+		ctx = context.WithValue(ctx, PaginationContextKey, params)
+	}
+
+	if filters := parseFilters(query); len(filters) > 0 {
+		ctx = context.WithValue(ctx, FilterContextKey, filters)
+	}
+
+	var sort []string
+	for _, field := range strings.Split(query.Get("sort"), ",") {
+		if field != "" {
+			sort = append(sort, field)
+		}
+	}
+	if sort != nil {
+		ctx = context.WithValue(ctx, SortContextKey, sort)
+	}
+
+	if fields := parseFieldSet(query, resourceType); fields != nil {
+		ctx = context.WithValue(ctx, FieldSetContextKey, fields)
+	}
+	return ctx
+}
@@ -0,0 +1,78 @@
+package jshapi
+
+import (
+	"net/http"
+	"strings"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+/*
+WithAllowedOrigins restricts the API to cross-origin requests from the given origins.
+Requests whose Origin header matches none of them are rejected with a 403 error, while
+matching requests, preflight OPTIONS requests included, get an
+`Access-Control-Allow-Origin: <origin>` header echoing the origin, never `*`, so that
+credentialed requests are supported. Requests without an Origin header are not affected.
+
+Origins are either exact, `https://app.example.com`, or match any subdomain with a
+wildcard, `*.example.com` for any scheme or `https://*.example.com`:
+
+	api := jshapi.New("api", jshapi.WithAllowedOrigins("https://example.com", "*.example.com"))
+*/
+func WithAllowedOrigins(origins ...string) APIOption {
+	return func(a *API) {
+		a.allowedOrigins = append(a.allowedOrigins, origins...)
+	}
+}
+
+// allowedOriginsMiddleware rejects the requests from origins that are not allowed.
+func (a *API) allowedOriginsMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !originAllowed(origin, a.allowedOrigins) {
+			SendHandler(ctx, w, r, jsh.ForbiddenError("Origin not allowed"))
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// originAllowed returns true if the origin matches one of the allowed origins.
+func originAllowed(origin string, allowed []string) bool {
+	for _, entry := range allowed {
+		if entry == origin {
+			return true
+		}
+
+		wildcard := strings.Index(entry, "*.")
+		if wildcard == -1 {
+			continue
+		}
+		host := origin
+		if scheme := entry[:wildcard]; scheme != "" {
+			if !strings.HasPrefix(origin, scheme) {
+				continue
+			}
+			host = origin[len(scheme):]
+		} else if index := strings.Index(origin, "://"); index != -1 {
+			host = origin[index+len("://"):]
+		}
+
+		// the subdomain must not be empty
+		suffix := entry[wildcard+1:]
+		if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
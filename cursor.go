@@ -0,0 +1,106 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+/*
+CursorList registers a `GET /resource` handler for the resource listing cursor paginated
+objects. The `page[after]` and `page[before]` query parameters are decoded with the encoder
+and passed to storage, and the cursors of the surrounding pages it returns are encoded in
+the `links.next` and `links.prev` members of the response document. It is a function
+rather than a method of Resource since methods cannot have type parameters:
+
+	jshapi.CursorList(resource, storage, store.Base64JSONEncoder[UserCursor]{}, true)
+*/
+func CursorList[T any](res *Resource, storage store.CursorList[T], encoder store.CursorEncoder[T], allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			cursorListHandler(res, ctx, w, r, storage, encoder)
+		}
+	}
+
+	res.HandleFuncC(pat.Get(patRoot), handler)
+	res.addRoute(head, patRoot, allow)
+	res.addRoute(get, patRoot, allow)
+}
+
+// GET /resources?page[after]=<cursor>
+func cursorListHandler[T any](res *Resource, ctx context.Context, w http.ResponseWriter, r *http.Request,
+	storage store.CursorList[T], encoder store.CursorEncoder[T]) {
+	w = res.readOnly(w)
+	after, parseErr := decodeCursor(r, "page[after]", encoder)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+	before, parseErr := decodeCursor(r, "page[before]", encoder)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	start := time.Now()
+	list, page, err := storage(ctx, after, before)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if list == nil {
+		list = jsh.List{}
+	}
+	for _, object := range list {
+		res.addLinks(object)
+	}
+
+	links := map[string]string{}
+	for _, link := range []struct {
+		rel, param string
+		cursor     *T
+	}{{"next", "page[after]", page.Next}, {"prev", "page[before]", page.Prev}} {
+		if link.cursor == nil {
+			continue
+		}
+		value, encodeErr := encoder.Encode(*link.cursor)
+		if encodeErr != nil {
+			SendHandler(ctx, w, r, jsh.ISE(fmt.Sprintf("Unable to encode pagination cursor: %s", encodeErr)))
+			return
+		}
+		query := r.URL.Query()
+		query.Del("page[after]")
+		query.Del("page[before]")
+		query.Set(link.param, value)
+		links[link.rel] = r.URL.Path + "?" + query.Encode()
+	}
+	if len(links) > 0 {
+		sendWithLinks(ctx, w, r, jsh.Build(list), links)
+		return
+	}
+	SendHandler(ctx, w, r, list)
+}
+
+// decodeCursor decodes the cursor of the query parameter, returning nil if it is not set.
+func decodeCursor[T any](r *http.Request, param string, encoder store.CursorEncoder[T]) (*T, *jsh.Error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	cursor, err := encoder.Decode(value)
+	if err != nil {
+		return nil, jsh.ParameterError("Invalid pagination cursor", param)
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,89 @@
+package jshapi
+
+import (
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+// debugRoutesTemplate renders the route tree of the API as an HTML table.
+var debugRoutesTemplate = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>jshapi routes</title>
+	<style>
+		body { font-family: monospace; }
+		td, th { padding: 2px 12px; text-align: left; }
+		.disallowed { color: #999; }
+	</style>
+</head>
+<body>
+	<table>
+		<tr><th>Method</th><th>Path</th><th>Allowed</th><th>Handler</th></tr>
+		{{- range .}}
+		<tr{{if not .Allow}} class="disallowed"{{end}}>
+			<td>{{.Method}}</td>
+			<td>{{if .Link}}<a href="{{.Path}}" target="_blank">{{.Path}}</a>{{else}}{{.Path}}{{end}}</td>
+			<td>{{.Allow}}</td>
+			<td>{{.Handler}}{{if .Action}} [ACTION] {{.Action}}{{end}}</td>
+		</tr>
+		{{- end}}
+	</table>
+</body>
+</html>
+`))
+
+// debugRoute is a route as displayed by the debug routes page.
+type debugRoute struct {
+	Route
+	// Handler is the path the handling resource is registered at
+	Handler string
+	// Action is the name of the custom action handling the route, if any
+	Action string
+	// Link is true if the route can be opened in a browser
+	Link bool
+}
+
+// mountDebugRoutes registers the `GET /debug/routes` and `GET /debug/errors` handlers for the API.
+func (a *API) mountDebugRoutes() {
+	a.router.HandleC(pat.Get(path.Join(a.prefix, "debug", "routes")), goji.HandlerFunc(a.debugRoutesHandler))
+	a.router.HandleC(pat.Get(path.Join(a.prefix, "debug", "errors")), goji.HandlerFunc(a.debugErrorsHandler))
+}
+
+// GET /debug/routes
+func (a *API) debugRoutesHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugRoutesTemplate.Execute(w, a.debugRoutes()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// debugRoutes lists all the routes of the API with their full path, sorted by resource path.
+func (a *API) debugRoutes() []debugRoute {
+	var routes []debugRoute
+	for _, matcher := range a.sortedResourcePaths() {
+		resource := a.Resources[matcher]
+		actions := map[Route]string{}
+		for name, action := range resource.Actions {
+			actions[action] = name
+		}
+
+		for _, route := range resource.Routes {
+			action := actions[route]
+			route.Path = matcher + strings.TrimPrefix(route.Path, "/"+resource.Type)
+			routes = append(routes, debugRoute{
+				Route:   route,
+				Handler: matcher,
+				Action:  action,
+				Link:    route.Method == get && route.Allow && !strings.Contains(route.Path, ":"),
+			})
+		}
+	}
+	return routes
+}
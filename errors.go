@@ -0,0 +1,104 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"goji.io"
+	"goji.io/middleware"
+	"golang.org/x/net/context"
+)
+
+// errorCounter counts the 4xx and 5xx responses of a route.
+type errorCounter [2]int64
+
+// trackErrors starts counting the error responses of each route of the resource,
+// it is enabled for the resources of an API in debug mode.
+func (res *Resource) trackErrors() {
+	if res.errorCounts == nil {
+		res.errorCounts = &sync.Map{}
+		res.UseC(res.errorCountMiddleware)
+	}
+}
+
+// ErrorCounts returns the number of 4xx and 5xx responses of each route of the resource,
+// keyed by "<METHOD> <path>". Errors are only counted once the resource is added to an
+// API in debug mode.
+func (res *Resource) ErrorCounts() map[string][2]int64 {
+	counts := map[string][2]int64{}
+	if res.errorCounts == nil {
+		return counts
+	}
+
+	res.errorCounts.Range(func(key, value interface{}) bool {
+		counter := value.(*errorCounter)
+		counts[key.(string)] = [2]int64{atomic.LoadInt64(&counter[0]), atomic.LoadInt64(&counter[1])}
+		return true
+	})
+	return counts
+}
+
+// ErrorReport aggregates the error counts of all the resources of the API.
+func (a *API) ErrorReport() map[string][2]int64 {
+	report := map[string][2]int64{}
+	for _, resource := range a.Resources {
+		for route, counts := range resource.ErrorCounts() {
+			report[route] = counts
+		}
+	}
+	return report
+}
+
+// ResetErrorCounts resets the error counts of all the resources of the API.
+func (a *API) ResetErrorCounts() {
+	for _, resource := range a.Resources {
+		if resource.errorCounts == nil {
+			continue
+		}
+		// clear the map in place since errorCountMiddleware reads it concurrently
+		resource.errorCounts.Range(func(key, value interface{}) bool {
+			resource.errorCounts.Delete(key)
+			return true
+		})
+	}
+}
+
+// routeKey returns the "<METHOD> <path>" key of the route of the resource matching the request,
+// or "<METHOD> /<type>/*" if no route of the resource matches it.
+func (res *Resource) routeKey(ctx context.Context, r *http.Request) string {
+	pattern := middleware.Pattern(ctx)
+	if pattern == nil {
+		return fmt.Sprintf("%s /%s/*", r.Method, res.Type)
+	}
+	return fmt.Sprintf("%s /%s%s", r.Method, res.Type, pattern)
+}
+
+// errorCountMiddleware counts the error responses of the route matching the request.
+func (res *Resource) errorCountMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTPC(ctx, sw, r)
+
+		counts := res.errorCounts
+		if counts == nil || sw.status < http.StatusBadRequest {
+			return
+		}
+		value, _ := counts.LoadOrStore(res.routeKey(ctx, r), &errorCounter{})
+		if sw.status < http.StatusInternalServerError {
+			atomic.AddInt64(&value.(*errorCounter)[0], 1)
+		} else {
+			atomic.AddInt64(&value.(*errorCounter)[1], 1)
+		}
+	})
+}
+
+// GET /debug/errors
+func (a *API) debugErrorsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.ErrorReport()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,37 @@
+package jshapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// HEAD /resources/:id
+func (res *Resource) headHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Get) {
+	hw := &headResponseWriter{ResponseWriter: w}
+	res.fetchHandler(ctx, hw, r, storage)
+}
+
+// headResponseWriter is a response writer discarding the response body, the Content-Length
+// header being set to the length of the discarded body if the handler did not set it.
+type headResponseWriter struct {
+	http.ResponseWriter
+	length int
+}
+
+// WriteHeader sets the Content-Length header before writing the status.
+func (w *headResponseWriter) WriteHeader(status int) {
+	if w.Header().Get("Content-Length") == "" && w.length > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write discards the content, only counting its length.
+func (w *headResponseWriter) Write(content []byte) (int, error) {
+	w.length += len(content)
+	return len(content), nil
+}
@@ -0,0 +1,57 @@
+package jshapi
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+/*
+ServeHealth registers a `GET <prefix>/<route>` health check handler for the API. The CRUD
+storage of each resource implementing store.HealthChecker is pinged, and the response has
+the following top-level meta:
+
+	{"meta": {"status": "ok", "uptime": "1h2m3s", "started_at": "2006-01-02T15:04:05Z", "version": "1.2.3"}}
+
+The version is omitted until set with SetVersion. When a storage fails to respond, the status
+is "unavailable", the errors are listed by resource type under "failures", and the response
+is a 503.
+*/
+func (a *API) ServeHealth(route string) {
+	a.router.HandleC(pat.Get(path.Join(a.prefix, route)), goji.HandlerFunc(a.healthHandler))
+}
+
+// GET /<route>
+func (a *API) healthHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	meta := map[string]interface{}{
+		"status":     "ok",
+		"uptime":     a.Uptime().Round(time.Second).String(),
+		"started_at": a.startedAt.UTC().Format(time.RFC3339),
+	}
+	if a.Version != "" {
+		meta["version"] = a.Version
+	}
+
+	failures := map[string]string{}
+	for _, matcher := range a.sortedResourcePaths() {
+		resource := a.Resources[matcher]
+		if resource.healthChecker == nil {
+			continue
+		}
+		if err := resource.healthChecker.Ping(ctx); err != nil {
+			failures[resource.Type] = err.Error()
+		}
+	}
+
+	doc := metaDocument(meta)
+	if len(failures) > 0 {
+		meta["status"] = "unavailable"
+		meta["failures"] = failures
+		doc.Status = http.StatusServiceUnavailable
+	}
+	SendHandler(ctx, w, r, doc)
+}
@@ -0,0 +1,68 @@
+package jshapi
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// ErrHookForbidden can be returned, or wrapped, by a CRUD hook to reject the request with a 403 error.
+var ErrHookForbidden = errors.New("forbidden")
+
+// CRUDHook runs before a CRUD handler calls its storage, it may return a derived context
+// passed to the following hooks and to the storage.
+type CRUDHook func(ctx context.Context, r *http.Request) (context.Context, error)
+
+// CRUDHooks holds the hooks run, in order, before each CRUD operation of a resource.
+type CRUDHooks struct {
+	PreSave   []CRUDHook
+	PreGet    []CRUDHook
+	PreList   []CRUDHook
+	PreUpdate []CRUDHook
+	PreDelete []CRUDHook
+}
+
+/*
+SetCRUDHooks registers hooks run before the storage of each CRUD operation, which is a
+simpler alternative to middleware when logic only needs to be injected at specific points:
+
+	resource.SetCRUDHooks(jshapi.CRUDHooks{
+		PreDelete: []jshapi.CRUDHook{func(ctx context.Context, r *http.Request) (context.Context, error) {
+			if r.Header.Get("X-Admin") == "" {
+				return ctx, jshapi.ErrHookForbidden
+			}
+			return ctx, nil
+		}},
+	})
+
+The first hook returning an error stops the request: errors wrapping ErrHookForbidden are
+sent as 403 errors, jsh errors are sent as is, and other errors are sent as 400 errors.
+*/
+func (res *Resource) SetCRUDHooks(hooks CRUDHooks) {
+	res.hooks = hooks
+}
+
+// runHooks runs the hooks in order, returning the error of the first failing one.
+func runHooks(ctx context.Context, r *http.Request, hooks []CRUDHook) (context.Context, jsh.ErrorType) {
+	for _, hook := range hooks {
+		next, err := hook(ctx, r)
+		if err == nil {
+			ctx = next
+			continue
+		}
+
+		var jshErr jsh.ErrorType
+		switch {
+		case errors.Is(err, ErrHookForbidden):
+			return ctx, jsh.ForbiddenError(err.Error())
+		case errors.As(err, &jshErr):
+			return ctx, jshErr
+		default:
+			return ctx, jsh.BadRequestError("Invalid request", err.Error())
+		}
+	}
+	return ctx, nil
+}
@@ -0,0 +1,46 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+)
+
+/*
+EnableHTTP2Hints adds a `Link: </resources/:id/<relationship>>; rel=preload` header to
+fetch responses for each to-one relationship of the resource. Reverse proxies supporting
+HTTP/2 server push can use these hints to push the related resources to the client.
+
+Hints are only sent when the response writer implements http.Pusher, i.e. over HTTP/2.
+*/
+func (res *Resource) EnableHTTP2Hints() {
+	res.http2Hints = true
+}
+
+// canPush returns true if HTTP/2 hints are enabled and the response writer supports server push.
+// Wrappers implementing Unwrap, which always implement http.Pusher, are skipped to check the
+// underlying response writer.
+func (res *Resource) canPush(w http.ResponseWriter) bool {
+	if !res.http2Hints {
+		return false
+	}
+	for {
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = wrapper.Unwrap()
+	}
+	_, ok := w.(http.Pusher)
+	return ok
+}
+
+// addPreloadHints adds a preload Link header for each to-one relationship of the fetched object.
+func (res *Resource) addPreloadHints(w http.ResponseWriter, r *http.Request) {
+	for _, name := range sortedRelationships(res) {
+		if res.Relationships[name] != ToOne {
+			continue
+		}
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=preload", path.Join(r.URL.Path, name)))
+	}
+}
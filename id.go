@@ -0,0 +1,73 @@
+package jshapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// IDParser splits a raw resource ID into named components.
+type IDParser func(rawID string) (map[string]string, error)
+
+/*
+SetIDParser defines how the fetch, patch and delete handlers split composite IDs of the
+resource. The components are stored in the context, see store.IDComponentsFromContext.
+A 400 error is sent if the ID cannot be parsed.
+
+	resource.SetIDParser(jshapi.CompositeIDParser(":", "orgId", "resourceId"))
+*/
+func (res *Resource) SetIDParser(parser func(rawID string) (map[string]string, error)) {
+	res.idParser = parser
+}
+
+// CompositeIDParser returns an IDParser splitting IDs on the separator, naming each part by its key.
+func CompositeIDParser(sep string, keys ...string) func(string) (map[string]string, error) {
+	return func(rawID string) (map[string]string, error) {
+		parts := strings.Split(rawID, sep)
+		if len(parts) != len(keys) {
+			return nil, fmt.Errorf("expected %d parts separated by '%s' in ID '%s'", len(keys), sep, rawID)
+		}
+
+		components := make(map[string]string, len(keys))
+		for i, key := range keys {
+			components[key] = parts[i]
+		}
+		return components, nil
+	}
+}
+
+/*
+SetIDTransformer defines how the fetch, patch and delete handlers translate the ID of the
+request URL to the ID passed to storage, e.g. to map a slug to a database UUID. The error
+returned by the transformer, if any, is sent as response. The ID parser of the resource,
+if any, splits the transformed ID.
+*/
+func (res *Resource) SetIDTransformer(fn func(ctx context.Context, rawID string) (string, jsh.ErrorType)) {
+	res.idTransformer = fn
+}
+
+// parseID transforms the ID if the resource has an ID transformer, and stores the components
+// of the transformed ID in the context if the resource has an ID parser.
+func (res *Resource) parseID(ctx context.Context, id string) (context.Context, string, jsh.ErrorType) {
+	if res.idTransformer != nil {
+		transformed, err := res.idTransformer(ctx, id)
+		if err != nil && reflect.ValueOf(err).IsNil() == false {
+			return ctx, id, err
+		}
+		id = transformed
+	}
+	if res.idParser == nil {
+		return ctx, id, nil
+	}
+
+	components, err := res.idParser(id)
+	if err != nil {
+		return ctx, id, jsh.BadRequestError("Invalid ID", err.Error())
+	}
+	return context.WithValue(ctx, store.IDComponentsKey, store.IDComponents(components)), id, nil
+}
@@ -0,0 +1,96 @@
+package jshapi
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// methodPattern is a pat.Pattern only matching a single HTTP method, for the methods
+// pat has no constructor for.
+type methodPattern struct {
+	*pat.Pattern
+	method string
+}
+
+// newMethodPattern returns a pattern matching the path pattern for the method.
+func newMethodPattern(method, pattern string) *methodPattern {
+	return &methodPattern{Pattern: pat.New(pattern), method: method}
+}
+
+// Match implements goji.Pattern.
+func (p *methodPattern) Match(ctx context.Context, r *http.Request) context.Context {
+	if r.Method != p.method {
+		return nil
+	}
+	return p.Pattern.Match(ctx, r)
+}
+
+// HTTPMethods returns the method matched by the pattern.
+func (p *methodPattern) HTTPMethods() map[string]struct{} {
+	return map[string]struct{}{p.method: {}}
+}
+
+// LinkHandler registers a `LINK /resource/:id` handler for the resource, associating the
+// resources identified in the body to the resource. It responds with 204 on success.
+func (res *Resource) LinkHandler(storage store.Linker, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.linkHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(newMethodPattern(link, patID), handler)
+	res.addRoute(link, patID, allow)
+}
+
+// UnlinkHandler registers a `UNLINK /resource/:id` handler for the resource, dissociating the
+// resources identified in the body from the resource. It responds with 204 on success.
+func (res *Resource) UnlinkHandler(storage store.Unlinker, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.linkHandler(ctx, w, r, store.Linker(storage))
+		}
+	}
+
+	res.HandleFuncC(newMethodPattern(unlink, patID), handler)
+	res.addRoute(unlink, patID, allow)
+}
+
+// LINK/UNLINK /resources/:id
+func (res *Resource) linkHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Linker) {
+	targets, parseErr := jsh.ParseRelationshipList(r)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+	if len(targets) == 0 {
+		SendHandler(ctx, w, r, jsh.BadRequestError("Invalid document", "Missing resources to link"))
+		return
+	}
+
+	ctx, id, idErr := res.parseID(ctx, pat.Param(ctx, "id"))
+	if idErr != nil {
+		SendHandler(ctx, w, r, idErr)
+		return
+	}
+
+	start := time.Now()
+	err := storage(ctx, id, targets)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	res.addCacheBustHeaders(w)
+	w.WriteHeader(http.StatusNoContent)
+}
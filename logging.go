@@ -0,0 +1,43 @@
+package jshapi
+
+import (
+	"net/http"
+	"time"
+
+	"goji.io"
+	"goji.io/pattern"
+	"golang.org/x/net/context"
+)
+
+// StructuredLogger receives a log entry for each request handled by a resource.
+// The id is empty for routes without resource ID.
+type StructuredLogger interface {
+	LogRequest(resourceType, method, id string, status int, duration time.Duration)
+}
+
+// EnableStructuredLogging logs each request handled by the resource with the logger, once
+// its response has been sent. A nil logger disables logging.
+func (res *Resource) EnableStructuredLogging(logger StructuredLogger) {
+	if res.structuredLogger == nil && logger != nil {
+		res.UseC(res.structuredLoggingMiddleware)
+	}
+	res.structuredLogger = logger
+}
+
+// structuredLoggingMiddleware logs the status and duration of each request with the structured logger.
+func (res *Resource) structuredLoggingMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		logger := res.structuredLogger
+		if logger == nil {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+
+		start := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTPC(ctx, sw, r)
+
+		id, _ := ctx.Value(pattern.Variable("id")).(string)
+		logger.LogRequest(res.Type, r.Method, id, sw.status, time.Since(start))
+	})
+}
@@ -0,0 +1,45 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"goji.io"
+	"golang.org/x/net/context"
+)
+
+/*
+SetTopLevelMeta adds the value returned by fn to the top-level meta of every response of
+the resource, under key, e.g. "warnings", "deprecated_since" or "rate_limit_remaining".
+The key is skipped when fn returns nil. Resource values override the ones of the API
+response meta function, see API.SetResponseMeta.
+*/
+func (res *Resource) SetTopLevelMeta(key string, fn func(ctx context.Context, r *http.Request) interface{}) {
+	if res.topLevelMeta == nil {
+		res.topLevelMeta = map[string]func(ctx context.Context, r *http.Request) interface{}{}
+		res.UseC(res.topLevelMetaMiddleware)
+	}
+	res.topLevelMeta[key] = fn
+}
+
+// topLevelMetaMiddleware merges the top-level meta of the resource into the response meta
+// function of the request, used by the SendHandler.
+func (res *Resource) topLevelMetaMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		apiMeta, _ := ctx.Value(responseMetaKey).(ResponseMetaFunc)
+		ctx = context.WithValue(ctx, responseMetaKey, ResponseMetaFunc(func(ctx context.Context, r *http.Request) map[string]interface{} {
+			meta := map[string]interface{}{}
+			if apiMeta != nil {
+				for key, value := range apiMeta(ctx, r) {
+					meta[key] = value
+				}
+			}
+			for key, fn := range res.topLevelMeta {
+				if value := fn(ctx, r); value != nil {
+					meta[key] = value
+				}
+			}
+			return meta
+		}))
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
@@ -0,0 +1,45 @@
+package jshapi
+
+import (
+	"net/http"
+	"strings"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+/*
+ReadOnlyMiddleware responds with 405 to requests whose method is not GET, HEAD or OPTIONS.
+Along with WriteOnlyMiddleware, it allows to apply different authentication middleware to
+read and write requests:
+
+	api.UseC(func(next goji.Handler) goji.Handler {
+		return jshapi.ReadOnlyMiddleware(apiKeyAuth(next))
+	})
+*/
+func ReadOnlyMiddleware(handler goji.Handler) goji.Handler {
+	return methodsMiddleware(handler, get, head, options)
+}
+
+// WriteOnlyMiddleware responds with 405 to requests whose method is not POST, PATCH or DELETE.
+func WriteOnlyMiddleware(handler goji.Handler) goji.Handler {
+	return methodsMiddleware(handler, post, patch, delete)
+}
+
+// methodsMiddleware responds with 405 and the Allow header to requests whose method is not allowed.
+func methodsMiddleware(handler goji.Handler, methods ...string) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method {
+				handler.ServeHTTPC(ctx, w, r)
+				return
+			}
+		}
+
+		w.Header().Add("Allow", strings.Join(methods, ","))
+		w.Header().Add("Content-Type", jsh.ContentType)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+}
@@ -1,13 +1,31 @@
 package jshapi
 
 import (
+	"encoding/json"
 	"log"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
 	"golang.org/x/net/context"
 )
 
+// Mock storages take part in health checks
+var (
+	_ store.HealthChecker = (*MockStorage)(nil)
+	_ store.HealthChecker = (*MockToOneStorage)(nil)
+	_ store.HealthChecker = (*MockToManyStorage)(nil)
+)
+
+// MockCall is a storage method call recorded by a mock storage.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
 // MockStorage allows you to mock out APIs really easily.
 // It is also used internally for testing the API layer.
 type MockStorage struct {
@@ -18,40 +36,182 @@ type MockStorage struct {
 	ResourceAttributes interface{}
 	// ListCount is the number of sample objects to return in a GET /resources request
 	ListCount int
+	// Latency is the time taken by Ping to respond
+	Latency time.Duration
+	// FixedList, if not nil, is returned by List instead of sample objects,
+	// and Get only finds the objects it contains
+	FixedList jsh.List
+	// ListSort, if not nil, orders the lists returned by List and SampleList,
+	// otherwise objects are returned in insertion order
+	ListSort func(a, b *jsh.Object) bool
+	// ComputedFields, if not nil, adds the value returned by each function for the object
+	// ID to the attributes of sample objects, under the function key
+	ComputedFields map[string]func(id string) interface{}
+	// CallLog records the storage method calls, except Ping, in call order
+	CallLog []MockCall
+	// callMutex protects CallLog
+	callMutex sync.Mutex
+	// Store, if not nil, acts as an in-memory database: Save appends to it, Get and List
+	// read from it, Update replaces and Delete removes objects by ID. It takes precedence
+	// over FixedList and sample objects, see NewStatefulMockStorage
+	Store []*jsh.Object
+	// storeMutex protects Store
+	storeMutex sync.RWMutex
+	// lastID is the ID of the last object saved to Store without ID
+	lastID int
+}
+
+// NewStatefulMockStorage returns a mock storage persisting objects to its Store, which
+// allows stateful tests to exercise full CRUD flows without a real database.
+func NewStatefulMockStorage(resourceType string) *MockStorage {
+	return &MockStorage{
+		ResourceType: resourceType,
+		Store:        []*jsh.Object{},
+	}
+}
+
+// storeIndex returns the index of the object with the ID in Store, or -1 if there is none.
+func (m *MockStorage) storeIndex(id string) int {
+	for i, object := range m.Store {
+		if object.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// record adds a call to the call log.
+func (m *MockStorage) record(method string, args ...interface{}) {
+	m.callMutex.Lock()
+	defer m.callMutex.Unlock()
+	m.CallLog = append(m.CallLog, MockCall{Method: method, Args: args})
+}
+
+// SortByID orders objects by ID string, it can be used as MockStorage.ListSort.
+func SortByID(a, b *jsh.Object) bool {
+	return a.ID < b.ID
 }
 
-// Save assigns a URL of 1 to the object
+// Save persists the object to Store if set, assigning it the next free ID if it has none,
+// and assigns an ID of 1 to the object otherwise
 func (m *MockStorage) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	m.record("Save", object)
+	if m.Store != nil {
+		m.storeMutex.Lock()
+		defer m.storeMutex.Unlock()
+		if object.ID == "" {
+			for object.ID == "" || m.storeIndex(object.ID) != -1 {
+				m.lastID++
+				object.ID = strconv.Itoa(m.lastID)
+			}
+		} else if m.storeIndex(object.ID) != -1 {
+			return nil, jsh.ConflictError(m.ResourceType, object.ID)
+		}
+		m.Store = append(m.Store, object)
+		return object, nil
+	}
 	object.ID = "1"
 	return object, nil
 }
 
-// Get returns a resource with ID as specified by the request
+// Get returns the object of Store with the ID if set, a resource with ID as specified by the request otherwise
 func (m *MockStorage) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	m.record("Get", id)
+	if m.Store != nil {
+		m.storeMutex.RLock()
+		defer m.storeMutex.RUnlock()
+		if i := m.storeIndex(id); i != -1 {
+			return m.Store[i], nil
+		}
+		return nil, jsh.NotFound(m.ResourceType, id)
+	}
+	if m.FixedList != nil {
+		for _, object := range m.FixedList {
+			if object.ID == id {
+				return object, nil
+			}
+		}
+		return nil, jsh.NotFound(m.ResourceType, id)
+	}
 	return m.SampleObject(id), nil
 }
 
-// List returns a sample list
+// List returns the objects of Store if set, the fixed list if set, a sample list otherwise
 func (m *MockStorage) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	m.record("List")
+	if m.Store != nil {
+		m.storeMutex.RLock()
+		defer m.storeMutex.RUnlock()
+		return m.sort(append(jsh.List{}, m.Store...)), nil
+	}
+	if m.FixedList != nil {
+		return m.sort(m.FixedList), nil
+	}
 	return m.SampleList(m.ListCount), nil
 }
 
-// Update does nothing
+// Update replaces the object with the same ID in Store if set, it does nothing otherwise
 func (m *MockStorage) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	m.record("Update", object)
+	if m.Store != nil {
+		m.storeMutex.Lock()
+		defer m.storeMutex.Unlock()
+		i := m.storeIndex(object.ID)
+		if i == -1 {
+			return nil, jsh.NotFound(m.ResourceType, object.ID)
+		}
+		m.Store[i] = object
+	}
 	return object, nil
 }
 
-// Delete does nothing
+// Delete removes the object with the ID from Store if set, it does nothing otherwise
 func (m *MockStorage) Delete(ctx context.Context, id string) jsh.ErrorType {
+	m.record("Delete", id)
+	if m.Store != nil {
+		m.storeMutex.Lock()
+		defer m.storeMutex.Unlock()
+		i := m.storeIndex(id)
+		if i == -1 {
+			return jsh.NotFound(m.ResourceType, id)
+		}
+		m.Store = append(m.Store[:i], m.Store[i+1:]...)
+	}
 	return nil
 }
 
+// Ping implements store.HealthChecker, it waits for Latency and fails if the context is done first
+func (m *MockStorage) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(m.Latency):
+		return ctx.Err()
+	}
+}
+
 // SampleObject builds an object based on provided resource specifications
 func (m *MockStorage) SampleObject(id string) *jsh.Object {
 	object, err := jsh.NewObject(id, m.ResourceType, m.ResourceAttributes)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	if len(m.ComputedFields) == 0 {
+		return object
+	}
+
+	attributes := map[string]interface{}{}
+	if len(object.Attributes) > 0 {
+		if err := json.Unmarshal(object.Attributes, &attributes); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	for field, compute := range m.ComputedFields {
+		attributes[field] = compute(id)
+	}
+	if err := object.Marshal(attributes); err != nil {
+		log.Fatal(err.Error())
+	}
 	return object
 }
 
@@ -62,26 +222,51 @@ func (m *MockStorage) SampleList(length int) jsh.List {
 	for id := 1; id <= length; id++ {
 		list = append(list, m.SampleObject(strconv.Itoa(id)))
 	}
-	return list
+	return m.sort(list)
 }
 
+// sort returns a copy of the list ordered by ListSort, or the list itself if ListSort is nil.
+func (m *MockStorage) sort(list jsh.List) jsh.List {
+	if m.ListSort == nil {
+		return list
+	}
+
+	sorted := make(jsh.List, len(list))
+	copy(sorted, list)
+	sort.Stable(objectSorter{list: sorted, less: m.ListSort})
+	return sorted
+}
+
+// objectSorter implements sort.Interface for a list using a custom ordering.
+type objectSorter struct {
+	list jsh.List
+	less func(a, b *jsh.Object) bool
+}
+
+func (s objectSorter) Len() int           { return len(s.list) }
+func (s objectSorter) Swap(i, j int)      { s.list[i], s.list[j] = s.list[j], s.list[i] }
+func (s objectSorter) Less(i, j int) bool { return s.less(s.list[i], s.list[j]) }
+
 // MockToOneStorage allows you to mock out APIs to-one relationships really easily. \
 // It is also used internally for testing the API layer.
 type MockToOneStorage MockStorage
 
 // Get returns the to-one relationship resource with ID as specified by the request
 func (m *MockToOneStorage) GetResource(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	(*MockStorage)(m).record("GetResource", id)
 	return m.SampleObject(id), nil
 }
 
 // GetRelationship returns the to-one relationship ID object as specified by the request
 func (m *MockToOneStorage) Get(ctx context.Context, id string) (*jsh.IDObject, jsh.ErrorType) {
+	(*MockStorage)(m).record("Get", id)
 	return m.SampleIDObject(id), nil
 }
 
 // Update does nothing
 func (m *MockToOneStorage) Update(ctx context.Context, id string,
 	relationship *jsh.IDObject) (*jsh.IDObject, jsh.ErrorType) {
+	(*MockStorage)(m).record("Update", id, relationship)
 	return nil, nil
 }
 
@@ -94,6 +279,11 @@ func (m *MockToOneStorage) SampleObject(id string) *jsh.Object {
 	return object
 }
 
+// Ping implements store.HealthChecker, it behaves like MockStorage.Ping
+func (m *MockToOneStorage) Ping(ctx context.Context) error {
+	return (*MockStorage)(m).Ping(ctx)
+}
+
 // SampleIDObject builds an ID object based on provided resource specifications
 func (m *MockToOneStorage) SampleIDObject(id string) *jsh.IDObject {
 	return jsh.NewIDObject(m.ResourceType, id)
@@ -105,26 +295,31 @@ type MockToManyStorage MockStorage
 
 // List returns the to-many relationship resources with ID as specified by the request
 func (m *MockToManyStorage) ListResources(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+	(*MockStorage)(m).record("ListResources", id)
 	return m.SampleList(id), nil
 }
 
 // ListRelationships returns the to-many relationship ID objects as specified by the request
 func (m *MockToManyStorage) List(ctx context.Context, id string) (jsh.IDList, jsh.ErrorType) {
+	(*MockStorage)(m).record("List", id)
 	return m.SampleIDList(id), nil
 }
 
 // Save does nothing
 func (m *MockToManyStorage) Save(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType) {
+	(*MockStorage)(m).record("Save", id, list)
 	return nil, nil
 }
 
 // Update does nothing
 func (m *MockToManyStorage) Update(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType) {
+	(*MockStorage)(m).record("Update", id, list)
 	return nil, nil
 }
 
 // Delete does nothing
 func (m *MockToManyStorage) Delete(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType) {
+	(*MockStorage)(m).record("Delete", id, list)
 	return nil, nil
 }
 
@@ -137,6 +332,11 @@ func (m *MockToManyStorage) SampleList(id string) jsh.List {
 	return jsh.List{object}
 }
 
+// Ping implements store.HealthChecker, it behaves like MockStorage.Ping
+func (m *MockToManyStorage) Ping(ctx context.Context) error {
+	return (*MockStorage)(m).Ping(ctx)
+}
+
 // SampleIDObject builds an ID object based on provided resource specifications
 func (m *MockToManyStorage) SampleIDList(id string) jsh.IDList {
 	return jsh.IDList{jsh.NewIDObject(m.ResourceType, id)}
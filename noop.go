@@ -0,0 +1,62 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// NoOpBehaviour defines how the PATCH /resources/:id handler responds to an update that
+// does not change the object.
+type NoOpBehaviour int
+
+const (
+	// CallStorageAnyway calls the storage regardless of the changes
+	CallStorageAnyway NoOpBehaviour = iota
+	// Return204 responds with 204 without calling the storage
+	Return204
+	// Return200WithOld responds with 200 and the current object without calling the storage
+	Return200WithOld
+)
+
+/*
+SetNoOpUpdateBehaviour defines how PATCH /resources/:id responds when the patched attributes
+all have their current value. Detecting no-op updates requires fetching the current object
+with the storage registered by Get before each update, so the default behaviour is
+CallStorageAnyway, which makes no such call. Updates including relationships are never
+considered no-ops.
+*/
+func (res *Resource) SetNoOpUpdateBehaviour(behaviour NoOpBehaviour) {
+	res.noOpUpdate = behaviour
+}
+
+// isNoOpUpdate returns true if the attributes of the patched object all have the value
+// they have in the current object.
+func isNoOpUpdate(current, patched *jsh.Object) bool {
+	if current == nil || current.Type != patched.Type || len(patched.Relationships) > 0 {
+		return false
+	}
+	if len(patched.Attributes) == 0 {
+		return true
+	}
+
+	currentAttrs := map[string]interface{}{}
+	patchedAttrs := map[string]interface{}{}
+	if len(current.Attributes) > 0 {
+		if err := json.Unmarshal(current.Attributes, &currentAttrs); err != nil {
+			return false
+		}
+	}
+	if err := json.Unmarshal(patched.Attributes, &patchedAttrs); err != nil {
+		return false
+	}
+
+	for key, value := range patchedAttrs {
+		currentValue, ok := currentAttrs[key]
+		if !ok || !reflect.DeepEqual(currentValue, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package jshapi
+
+import (
+	"goji.io"
+
+	"github.com/derekdowling/go-stdlogger"
+)
+
+// APIOption configures an API when passed to New.
+type APIOption func(*API)
+
+// WithDebug enables debug mode for the API.
+func WithDebug() APIOption {
+	return func(a *API) {
+		a.Debug = true
+	}
+}
+
+// WithLogger sets the logger used by the SendHandler and registers the request
+// logging middleware.
+func WithLogger(logger std.Logger) APIOption {
+	return func(a *API) {
+		a.logger = logger
+	}
+}
+
+// WithMiddleware registers a top level Goji middleware. Middleware is applied in
+// the order the options are given, after the logger middleware.
+func WithMiddleware(middleware func(goji.Handler) goji.Handler) APIOption {
+	return func(a *API) {
+		a.middleware = append(a.middleware, middleware)
+	}
+}
+
+// WithMaxPageSize sets the maximum page size of the paginated lists of the resources added
+// to the API, larger `page[size]` and `page[limit]` query parameters being lowered to it.
+func WithMaxPageSize(size int) APIOption {
+	return func(a *API) {
+		a.MaxPageSize = size
+	}
+}
+
+// WithBaseURL sets the absolute URL the API is served from, used as the `{{baseUrl}}` of
+// the collection generated by ExportPostman.
+func WithBaseURL(url string) APIOption {
+	return func(a *API) {
+		a.BaseURL = url
+	}
+}
@@ -0,0 +1,155 @@
+package jshapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+/*
+PagedList registers a `GET /resource` handler for the resource listing a page of objects.
+The `page[number]`, `page[size]`, `page[offset]` and `page[limit]` query parameters are
+parsed into a store.Pagination passed to storage, a 400 error being sent if one of them
+is invalid. The page size and limit are lowered to the MaxPageSize of the API, if any.
+Use CountedPagedList to add links to the surrounding pages.
+*/
+func (res *Resource) PagedList(storage store.PagedList, allow bool) {
+	res.CountedPagedList(func(ctx context.Context, p store.Pagination) (store.PageResult, jsh.ErrorType) {
+		list, err := storage(ctx, p)
+		return store.PageResult{List: list, Total: -1}, err
+	}, allow)
+}
+
+/*
+CountedPagedList registers a `GET /resource` handler for the resource like PagedList, for
+a storage also returning the total number of objects. When the total is known and the
+request has a page size, `page[size]` or `page[limit]`, the response document carries
+`links.first`, `links.prev`, `links.next` and `links.last` members.
+*/
+func (res *Resource) CountedPagedList(storage store.CountedPagedList, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.pagedListHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Get(patRoot), handler)
+	res.addRoute(head, patRoot, allow)
+	res.addRoute(get, patRoot, allow)
+}
+
+// GET /resources?page[number]=<number>&page[size]=<size>
+func (res *Resource) pagedListHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.CountedPagedList) {
+	w = res.readOnly(w)
+	ctx = withListQuery(ctx, r.URL.Query(), res.Type)
+	pagination, parseErr := parsePagination(r.URL.Query())
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+	if max := res.maxPageSize; max > 0 {
+		if pagination.Size > max {
+			pagination.Size = max
+		}
+		if pagination.Limit > max {
+			pagination.Limit = max
+		}
+	}
+
+	start := time.Now()
+	page, err := storage(ctx, pagination)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	list := page.List
+	if list == nil {
+		list = jsh.List{}
+	}
+	for _, object := range list {
+		res.addLinks(object)
+	}
+
+	links := map[string]string{}
+	for _, link := range paginationLinks(pagination, page.Total) {
+		query := r.URL.Query()
+		for param, value := range link.params {
+			query.Set(param, strconv.Itoa(value))
+		}
+		links[link.rel] = r.URL.Path + "?" + query.Encode()
+	}
+	if len(links) > 0 {
+		sendWithLinks(ctx, w, r, jsh.Build(list), links)
+		return
+	}
+	SendHandler(ctx, w, r, list)
+}
+
+// paginationLink is a link to a page of a paginated list, with the page parameters to set.
+type paginationLink struct {
+	rel    string
+	params map[string]int
+}
+
+// paginationLinks returns the links to the first, previous, next and last pages, or nil
+// if the total is unknown or the request has no page size.
+func paginationLinks(p store.Pagination, total int) []paginationLink {
+	var links []paginationLink
+	switch {
+	case total < 0:
+		return nil
+	case p.Size > 0:
+		number := p.Number
+		if number == 0 {
+			number = 1
+		}
+		last := (total + p.Size - 1) / p.Size
+		if last == 0 {
+			last = 1
+		}
+		page := func(rel string, number int) paginationLink {
+			return paginationLink{rel, map[string]int{"page[number]": number, "page[size]": p.Size}}
+		}
+
+		links = append(links, page("first", 1))
+		if number > 1 {
+			links = append(links, page("prev", number-1))
+		}
+		if number < last {
+			links = append(links, page("next", number+1))
+		}
+		links = append(links, page("last", last))
+	case p.Limit > 0:
+		last := 0
+		if total > 0 {
+			last = (total - 1) / p.Limit * p.Limit
+		}
+		page := func(rel string, offset int) paginationLink {
+			return paginationLink{rel, map[string]int{"page[offset]": offset, "page[limit]": p.Limit}}
+		}
+
+		links = append(links, page("first", 0))
+		if p.Offset > 0 {
+			prev := p.Offset - p.Limit
+			if prev < 0 {
+				prev = 0
+			}
+			links = append(links, page("prev", prev))
+		}
+		if p.Offset+p.Limit < total {
+			links = append(links, page("next", p.Offset+p.Limit))
+		}
+		links = append(links, page("last", last))
+	}
+	return links
+}
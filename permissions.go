@@ -0,0 +1,58 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"goji.io"
+	"golang.org/x/net/context"
+)
+
+// PermissionsKey is the context key holding the Permissions of the requesting user.
+const PermissionsKey = permissionsKey
+
+// Permissions describes what the requesting user is allowed to do with a resource.
+type Permissions struct {
+	CanCreate bool
+	CanRead   bool
+	CanUpdate bool
+	CanDelete bool
+}
+
+// PermissionsExtractor computes the permissions of the user making the request.
+type PermissionsExtractor func(r *http.Request) Permissions
+
+/*
+SetPermissionsExtractor registers a function computing the permissions of the requesting
+user before the storage of the resource is called. The permissions are stored in the
+context under PermissionsKey, so that storage implementations can filter their results:
+
+	func (s *UserStorage) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+		permissions, _ := jshapi.PermissionsFromContext(ctx)
+		if !permissions.CanRead {
+			return jsh.List{}, nil
+		}
+		...
+	}
+*/
+func (res *Resource) SetPermissionsExtractor(extractor PermissionsExtractor) {
+	if res.permissionsExtractor == nil {
+		res.UseC(res.permissionsMiddleware)
+	}
+	res.permissionsExtractor = extractor
+}
+
+// PermissionsFromContext returns the permissions stored in the context, if any.
+func PermissionsFromContext(ctx context.Context) (Permissions, bool) {
+	permissions, ok := ctx.Value(PermissionsKey).(Permissions)
+	return permissions, ok
+}
+
+// permissionsMiddleware stores the permissions of the requesting user in the context.
+func (res *Resource) permissionsMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if res.permissionsExtractor != nil {
+			ctx = context.WithValue(ctx, PermissionsKey, res.permissionsExtractor(r))
+		}
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
@@ -0,0 +1,173 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// postmanSchema is the schema of the Postman collections exported by the API.
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanCollection is a Postman Collection v2.1.
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+type postmanItem struct {
+	Name    string         `json:"name"`
+	Request postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanHeader `json:"header"`
+	URL    postmanURL      `json:"url"`
+	Body   *postmanBody    `json:"body,omitempty"`
+}
+
+type postmanHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Host     []string          `json:"host"`
+	Path     []string          `json:"path"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+}
+
+type postmanVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type postmanBody struct {
+	Mode string `json:"mode"`
+	Raw  string `json:"raw"`
+}
+
+/*
+ExportPostman generates a Postman Collection v2.1 holding a request for each allowed route
+of the API, except HEAD and OPTIONS ones. Requests are sent to the `{{baseUrl}}` collection
+variable, which defaults to the API BaseURL, and `:id` path segments are Postman path
+variables. POST and PATCH requests of resources and relationships have a sample body,
+built from the first test data object of the resource if any, see Resource.SetTestData.
+*/
+func (a *API) ExportPostman() ([]byte, error) {
+	name := strings.Trim(a.prefix, "/")
+	if name == "" {
+		name = "api"
+	}
+
+	collection := postmanCollection{
+		Info:     postmanInfo{Name: name, Schema: postmanSchema},
+		Item:     []postmanItem{},
+		Variable: []postmanVariable{{Key: "baseUrl", Value: a.BaseURL}},
+	}
+	for _, route := range a.debugRoutes() {
+		if !route.Allow || route.Method == head || route.Method == options {
+			continue
+		}
+
+		item := postmanItem{
+			Name: route.String(),
+			Request: postmanRequest{
+				Method: route.Method,
+				Header: []postmanHeader{{Key: "Content-Type", Value: jsh.ContentType}},
+				URL:    newPostmanURL(route.Path),
+			},
+		}
+		if route.Method == post || route.Method == patch {
+			item.Request.Body = a.Resources[route.Handler].postmanBody(route)
+		}
+		collection.Item = append(collection.Item, item)
+	}
+	return json.MarshalIndent(collection, "", "  ")
+}
+
+// ServePostman registers a `GET <prefix>/<route>` handler for the API, downloading the
+// collection generated by ExportPostman.
+func (a *API) ServePostman(route string) {
+	a.router.HandleC(pat.Get(path.Join(a.prefix, route)), goji.HandlerFunc(a.postmanHandler))
+}
+
+// GET /<route>
+func (a *API) postmanHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	collection, err := a.ExportPostman()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="collection.postman_collection.json"`)
+	w.Write(collection)
+}
+
+// newPostmanURL builds the Postman URL of a route path, `:<name>` segments being path variables.
+func newPostmanURL(routePath string) postmanURL {
+	url := postmanURL{
+		Raw:  "{{baseUrl}}" + routePath,
+		Host: []string{"{{baseUrl}}"},
+		Path: []string{},
+	}
+	for _, segment := range strings.Split(strings.Trim(routePath, "/"), "/") {
+		if strings.HasPrefix(segment, ":") {
+			url.Variable = append(url.Variable, postmanVariable{Key: segment[1:]})
+		}
+		url.Path = append(url.Path, segment)
+	}
+	return url
+}
+
+// postmanBody returns a sample body for a POST or PATCH route of the resource,
+// or nil for actions and other custom routes.
+func (res *Resource) postmanBody(route debugRoute) *postmanBody {
+	segments := strings.Split(route.Path, "/")
+	var data interface{}
+	switch {
+	case strings.HasSuffix(route.Path, "/"+res.Type):
+		data = res.postmanObject("")
+	case strings.HasSuffix(route.Path, "/"+res.Type+patID):
+		data = res.postmanObject("{{id}}")
+	case len(segments) > 2 && segments[len(segments)-2] == "relationships":
+		data = []interface{}{}
+		if res.Relationships[segments[len(segments)-1]] == ToOne {
+			data = nil
+		}
+	default:
+		return nil
+	}
+
+	body, _ := json.MarshalIndent(map[string]interface{}{"data": data}, "", "  ")
+	return &postmanBody{Mode: "raw", Raw: string(body)}
+}
+
+// postmanObject returns a sample object of the resource type, with the attributes of
+// the first test data object if any.
+func (res *Resource) postmanObject(id string) map[string]interface{} {
+	object := map[string]interface{}{"type": res.Type, "attributes": map[string]interface{}{}}
+	if id != "" {
+		object["id"] = id
+	}
+	if len(res.testData) > 0 && len(res.testData[0].Attributes) > 0 {
+		object["attributes"] = res.testData[0].Attributes
+	}
+	return object
+}
@@ -0,0 +1,46 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"goji.io"
+	"golang.org/x/net/context"
+)
+
+// WithPrincipal returns a copy of the context holding the principal making the request.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, ActorContextKey, principal)
+}
+
+/*
+PrincipalFromContext returns the principal making the request, if any. Storage and action
+handlers should use it for auditing rather than parsing request headers themselves:
+
+	func approve(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+		principal, _ := jshapi.PrincipalFromContext(ctx)
+		...
+	}
+*/
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(ActorContextKey).(string)
+	return principal, ok
+}
+
+/*
+PrincipalMiddleware stores the principal returned by the extractor in the context of
+each request. The principal is not set when the extractor returns an empty string:
+
+	api := jshapi.New("", jshapi.WithMiddleware(jshapi.PrincipalMiddleware(func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})))
+*/
+func PrincipalMiddleware(extractor func(r *http.Request) string) func(goji.Handler) goji.Handler {
+	return func(next goji.Handler) goji.Handler {
+		return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if principal := extractor(r); principal != "" {
+				ctx = WithPrincipal(ctx, principal)
+			}
+			next.ServeHTTPC(ctx, w, r)
+		})
+	}
+}
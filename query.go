@@ -0,0 +1,218 @@
+package jshapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// parseFilters extracts the `filter[<field>]=<value>[,<value>]` query parameters.
+func parseFilters(query url.Values) store.FilterMap {
+	filters := store.FilterMap{}
+	for key, values := range query {
+		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+
+		field := key[len("filter[") : len(key)-1]
+		for _, value := range values {
+			filters[field] = append(filters[field], strings.Split(value, ",")...)
+		}
+	}
+	return filters
+}
+
+// parseTypedFilters parses the filters of the fields of the schema according to their type.
+// Filters of fields missing from the schema are ignored.
+func parseTypedFilters(query url.Values, schema map[string]string) (store.TypedFilterMap, *jsh.Error) {
+	filters := store.TypedFilterMap{}
+	for field, values := range parseFilters(query) {
+		expectedType, exists := schema[field]
+		if !exists {
+			continue
+		}
+
+		parsed := make([]store.FilterValue, 0, len(values))
+		for _, value := range values {
+			filterValue, err := store.ParseFilterValue(value, expectedType)
+			if err != nil {
+				param := fmt.Sprintf("filter[%s]", field)
+				return nil, jsh.ParameterError(fmt.Sprintf("Expected %s value for %s", expectedType, param), param)
+			}
+			parsed = append(parsed, filterValue)
+		}
+
+		if len(parsed) == 1 {
+			filters[field] = parsed[0]
+		} else {
+			filters[field] = parsed
+		}
+	}
+	return filters, nil
+}
+
+// parseQueryParams extracts the `filter[<field>]=<value>` and `sort=<field>,-<field>` query
+// parameters, returning a 400 error if a sort field is empty or malformed.
+func parseQueryParams(query url.Values) (store.QueryParams, *jsh.Error) {
+	params := store.QueryParams{Filters: map[string]string{}}
+	for key := range query {
+		if strings.HasPrefix(key, "filter[") && strings.HasSuffix(key, "]") {
+			params.Filters[key[len("filter["):len(key)-1]] = query.Get(key)
+		}
+	}
+
+	if _, exists := query["sort"]; !exists {
+		return params, nil
+	}
+	for _, field := range strings.Split(query.Get("sort"), ",") {
+		sortField := store.SortField{Field: strings.TrimPrefix(field, "-")}
+		sortField.Descending = sortField.Field != field
+		if sortField.Field == "" || strings.HasPrefix(sortField.Field, "-") || strings.Contains(sortField.Field, " ") {
+			return params, jsh.ParameterError(fmt.Sprintf("Invalid sort field '%s'", field), "sort")
+		}
+		params.Sort = append(params.Sort, sortField)
+	}
+	return params, nil
+}
+
+// parsePagination extracts the `page[number]`, `page[size]`, `page[offset]` and `page[limit]`
+// query parameters, page numbers, sizes and limits must be positive and offsets not negative.
+func parsePagination(query url.Values) (store.Pagination, *jsh.Error) {
+	pagination := store.Pagination{}
+	for _, param := range []struct {
+		name  string
+		value *int
+		min   int
+	}{
+		{"page[number]", &pagination.Number, 1},
+		{"page[size]", &pagination.Size, 1},
+		{"page[offset]", &pagination.Offset, 0},
+		{"page[limit]", &pagination.Limit, 1},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < param.min {
+			return pagination, jsh.ParameterError(fmt.Sprintf("Expected integer of at least %d for %s", param.min, param.name), param.name)
+		}
+		*param.value = parsed
+	}
+	return pagination, nil
+}
+
+// parseFieldSet extracts the sparse fieldset `fields[<type>]=<field>,<field>` of the
+// given resource type, and returns nil if the query does not restrict its fields.
+func parseFieldSet(query url.Values, resourceType string) []string {
+	values, exists := query[fmt.Sprintf("fields[%s]", resourceType)]
+	if !exists {
+		return nil
+	}
+
+	fields := []string{}
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// unrecognisedParameters returns the sorted query parameters that are not defined by
+// the JSON API specification: page[*], filter[*], sort, include and fields[*].
+func unrecognisedParameters(query url.Values) []string {
+	var params []string
+	for key := range query {
+		switch {
+		case key == "sort", key == "include":
+		case strings.HasSuffix(key, "]") && (strings.HasPrefix(key, "page[") ||
+			strings.HasPrefix(key, "filter[") || strings.HasPrefix(key, "fields[")):
+		default:
+			params = append(params, key)
+		}
+	}
+	sort.Strings(params)
+	return params
+}
+
+// parseSearchAfter decodes the opaque `page[after]=<cursor>` query parameter, which is the
+// base64 encoded JSON of a store.SearchAfterCursor. It returns nil for an empty cursor.
+func parseSearchAfter(value string) (*store.SearchAfterCursor, *jsh.Error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	cursor := &store.SearchAfterCursor{}
+	if err != nil || json.Unmarshal(content, cursor) != nil || cursor.LastID == "" {
+		return nil, jsh.ParameterError("Invalid pagination cursor", "page[after]")
+	}
+	return cursor, nil
+}
+
+// encodeSearchAfter encodes the cursor for the `page[after]` query parameter.
+func encodeSearchAfter(cursor *store.SearchAfterCursor) string {
+	content, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// nextSearchAfter returns the cursor following the last object of the list, using the
+// first field of the `sort` query parameter as the cursor field, or nil for an empty list.
+func nextSearchAfter(list jsh.List, query url.Values) *store.SearchAfterCursor {
+	if len(list) == 0 {
+		return nil
+	}
+
+	last := list[len(list)-1]
+	cursor := &store.SearchAfterCursor{LastID: last.ID}
+	field := strings.TrimPrefix(strings.Split(query.Get("sort"), ",")[0], "-")
+	if field == "" {
+		return cursor
+	}
+
+	attributes := map[string]interface{}{}
+	if json.Unmarshal(last.Attributes, &attributes) == nil && attributes[field] != nil {
+		cursor.LastField = fmt.Sprint(attributes[field])
+	}
+	return cursor
+}
+
+// includedObjects returns the related objects of the relationships requested by the
+// `include=<relationship>,<relationship>` query parameter, or of all the relationships
+// if it is not set, without duplicates.
+func includedObjects(related map[string]jsh.List, query url.Values) []*jsh.Object {
+	names := make([]string, 0, len(related))
+	if include := query.Get("include"); include != "" {
+		for _, name := range strings.Split(include, ",") {
+			names = append(names, strings.SplitN(name, ".", 2)[0])
+		}
+	} else {
+		for name := range related {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	included := []*jsh.Object{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		for _, object := range related[name] {
+			key := object.Type + "/" + object.ID
+			if !seen[key] {
+				seen[key] = true
+				included = append(included, object)
+			}
+		}
+	}
+	return included
+}
@@ -0,0 +1,44 @@
+package jshapi
+
+import "net/http"
+
+/*
+SetStrictReadOnly defines whether the fetch and list handlers of the resource check that
+the response is written exactly once. When enabled, the handlers panic if the response body
+is written more than once, or if a 2xx status is written after the body, which reveals a
+storage writing to the response before returning its object.
+*/
+func (res *Resource) SetStrictReadOnly(enabled bool) {
+	res.strictReadOnly = enabled
+}
+
+// readOnly wraps the response writer in a readOnlyResponseWriter if strict read only is enabled.
+func (res *Resource) readOnly(w http.ResponseWriter) http.ResponseWriter {
+	if !res.strictReadOnly {
+		return w
+	}
+	return &readOnlyResponseWriter{ResponseWriter: w}
+}
+
+// readOnlyResponseWriter is a response writer panicking when the response is written twice.
+type readOnlyResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+// WriteHeader panics if a successful status is written after the response body.
+func (w *readOnlyResponseWriter) WriteHeader(status int) {
+	if w.written && status >= 200 && status < 300 {
+		panic("jshapi: status written after the response body of a read only handler")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write panics if the response body has already been written.
+func (w *readOnlyResponseWriter) Write(content []byte) (int, error) {
+	if w.written {
+		panic("jshapi: response body written twice by a read only handler")
+	}
+	w.written = true
+	return w.ResponseWriter.Write(content)
+}
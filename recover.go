@@ -0,0 +1,62 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// PanicFormatter builds the error response sent when a resource handler panics.
+type PanicFormatter func(recovered interface{}, stack []byte) jsh.ErrorType
+
+/*
+SetPanicFormatter customizes the error sent when a handler of the resource panics.
+The formatter receives the recovered value and the stack of the panicking goroutine.
+
+Without a custom formatter, a generic 500 error is sent. The panic value and stack
+are logged by the default SendHandler, and the panic value is also exposed in the
+`"panic"` top-level meta of the response when the resource is added to an API in debug mode.
+*/
+func (res *Resource) SetPanicFormatter(formatter PanicFormatter) {
+	res.panicFormatter = formatter
+}
+
+// recoverMiddleware sends the error built by the panic formatter when a handler panics.
+func (res *Resource) recoverMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			if res.panicFormatter != nil {
+				SendHandler(ctx, w, r, res.panicFormatter(recovered, debug.Stack()))
+				return
+			}
+			SendHandler(ctx, w, r, res.defaultPanicResponse(recovered, debug.Stack()))
+		}()
+
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// defaultPanicResponse returns a 500 error containing the panic value and stack as internal
+// message. In debug mode, it is sent in a document with the panic value as top-level meta,
+// since jsh errors have no meta member, and logged here as the SendHandler only logs errors.
+func (res *Resource) defaultPanicResponse(recovered interface{}, stack []byte) jsh.Sendable {
+	err := jsh.ISE(fmt.Sprintf("panic: %v\n%s", recovered, stack))
+	if !res.debug {
+		return err
+	}
+
+	Logger.Printf("Returning ISE: %s\n", err.Error())
+	doc := jsh.Build(err)
+	doc.Meta = map[string]interface{}{"panic": fmt.Sprint(recovered)}
+	return doc
+}
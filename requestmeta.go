@@ -0,0 +1,47 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api/store"
+)
+
+// RequestMetaHeader is the header holding the storage hints of a request, see store.RequestMeta.
+const RequestMetaHeader = "X-Request-Meta"
+
+// requestMetaMiddleware stores the decoded request meta header in the context,
+// and responds with 400 if it is not a flat JSON object.
+func requestMetaMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		meta, err := parseRequestMeta(r.Header.Get(RequestMetaHeader))
+		if err != nil {
+			SendHandler(ctx, w, r, err)
+			return
+		}
+		next.ServeHTTPC(context.WithValue(ctx, store.RequestMetaKey, meta), w, r)
+	})
+}
+
+// parseRequestMeta decodes the value of the request meta header.
+func parseRequestMeta(header string) (store.RequestMeta, *jsh.Error) {
+	meta := store.RequestMeta{}
+	if header == "" {
+		return meta, nil
+	}
+
+	if err := json.Unmarshal([]byte(header), &meta); err != nil {
+		return nil, jsh.BadRequestError("Invalid request meta", "The "+RequestMetaHeader+" header must be a JSON object")
+	}
+	for key, value := range meta {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, jsh.BadRequestError("Invalid request meta", "The "+RequestMetaHeader+" header must be a flat JSON object, '"+key+"' is nested")
+		}
+	}
+	return meta, nil
+}
@@ -1,11 +1,16 @@
 package jshapi
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"path"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"goji.io"
 	"goji.io/pat"
@@ -18,19 +23,52 @@ import (
 )
 
 const (
-	post    = "POST"
-	get     = "GET"
-	delete  = "DELETE"
-	patch   = "PATCH"
-	head    = "HEAD"
-	options = "OPTIONS"
-	patID   = "/:id"
-	patRoot = ""
+	post        = "POST"
+	get         = "GET"
+	delete      = "DELETE"
+	patch       = "PATCH"
+	head        = "HEAD"
+	options     = "OPTIONS"
+	link        = "LINK"
+	unlink      = "UNLINK"
+	patID       = "/:id"
+	patRoot     = ""
+	patSearch   = "/search"
+	patArchived = "/archived"
+	patCount    = "/count"
+	patRandom   = "/random"
 )
 
+// NDJSONContentType is the content type of streamed responses, made of one JSON document per line.
+const NDJSONContentType = "application/x-ndjson"
+
 // EnableClientGeneratedIDs is an option that allows consumers to allow for client generated IDs.
+// When disabled, POST requests whose object has an ID are rejected with a 403 error, an empty
+// `"id": ""` being treated as no ID, and IDs made of whitespace only are rejected with a 400
+// error regardless of the option.
+// It is ignored by resources with a client ID header, see Resource.SetClientIDHeader.
 var EnableClientGeneratedIDs bool
 
+// VaryAcceptEncoding is an option that adds Accept-Encoding to the Vary header of OPTIONS
+// responses, it should be enabled when responses are compressed by a middleware.
+var VaryAcceptEncoding bool
+
+// IdempotencyTTL is the duration idempotent action responses are cached for.
+// When zero, the default TTL of the cache is used.
+var IdempotencyTTL time.Duration
+
+// EmptyListBehaviour defines how the GET /resources handler responds when there is no object to list.
+type EmptyListBehaviour int
+
+const (
+	// Return200 responds with 200 and an empty data array, even if storage returned a 404 error
+	Return200 EmptyListBehaviour = iota
+	// Return404 responds with a 404 error when storage returned no object
+	Return404
+	// ReturnStorage sends whatever storage returned
+	ReturnStorage
+)
+
 // Route represents a resource route.
 type Route struct {
 	Method string
@@ -40,7 +78,31 @@ type Route struct {
 
 // String implements the Stringer interface for Route.
 func (r Route) String() string {
-	return fmt.Sprintf("%-7s - %s", r.Method, r.Path)
+	return r.Format(7, 0)
+}
+
+// Format returns the route with its method and path left aligned in columns of the given widths.
+func (r Route) Format(methodWidth, pathWidth int) string {
+	return fmt.Sprintf("%-*s - %-*s", methodWidth, r.Method, pathWidth, r.Path)
+}
+
+// actionLabel prefixes the custom action routes of route trees.
+const actionLabel = "[ACTION] "
+
+// routeWidths returns the width of the action label column, zero if no route of the resources
+// is an action, and the length of the longest method of their routes.
+func routeWidths(resources ...*Resource) (labelWidth, methodWidth int) {
+	for _, resource := range resources {
+		for _, route := range resource.Routes {
+			if resource.isAction(route) {
+				labelWidth = len(actionLabel)
+			}
+			if len(route.Method) > methodWidth {
+				methodWidth = len(route.Method)
+			}
+		}
+	}
+	return labelWidth, methodWidth
 }
 
 /*
@@ -72,6 +134,85 @@ type Resource struct {
 	Routes []Route
 	// Map of relationships
 	Relationships map[string]Relationship
+	// Actions maps the name of each custom action to its route
+	Actions map[string]Route
+	// selfLinks enables the links objects of the resource objects sent by the resource
+	selfLinks bool
+	// emptyList defines the response of the list handler when there is no object to list
+	emptyList EmptyListBehaviour
+	// deleteReasonRequired rejects deletions without a reason query parameter
+	deleteReasonRequired bool
+	// panicFormatter builds the error sent when a handler panics
+	panicFormatter PanicFormatter
+	// debug is set when the resource is added to an API in debug mode
+	debug bool
+	// maxPageSize is the MaxPageSize of the API the resource is added to
+	maxPageSize int
+	// timings maps route keys to their *timingBucket when timing is enabled
+	timings *sync.Map
+	// permissionsExtractor computes the permissions stored in the context of each request
+	permissionsExtractor PermissionsExtractor
+	// auditor receives an event after each mutation if the CRUD storage implements it
+	auditor store.CloudEventAuditor
+	// cacheBust adds cache invalidation headers to mutation responses
+	cacheBust bool
+	// testData seeds the mock storage used in test mode
+	testData []*jsh.Object
+	// strictReadOnly panics when the fetch and list handlers write their response twice
+	strictReadOnly bool
+	// http2Hints adds preload Link headers for to-one relationships to fetch responses
+	http2Hints bool
+	// clientIDHeader allows client generated IDs for requests having this header
+	clientIDHeader string
+	// filterSchema maps filterable fields to their type for the list handler
+	filterSchema map[string]string
+	// parameterWarnings adds a Warning header for each unrecognised list query parameter
+	parameterWarnings bool
+	// getStorage is the storage of the `GET /resource/:id` handler
+	getStorage store.Get
+	// staticRoutes are the GET routes with a static path, such as /search, which the /:id routes do not match
+	staticRoutes []*pat.Pattern
+	// deleteRequiresGet fetches the object before deleting it
+	deleteRequiresGet bool
+	// circuitBreaker wraps the requests handled by the resource
+	circuitBreaker CircuitBreaker
+	// idParser splits composite resource IDs into components
+	idParser IDParser
+	// idTransformer translates the IDs of the request URLs to storage IDs
+	idTransformer func(ctx context.Context, rawID string) (string, jsh.ErrorType)
+	// readCache caches the Get and List storage results, see API.EnableReadCache
+	readCache *store.CachedCRUD
+	// noCaching opts the resource out of the API read cache
+	noCaching bool
+	// cors adds the Access-Control-Allow-Methods header to OPTIONS responses
+	cors bool
+	// counter is the storage of the `GET /resource/count` handler, or the CRUD storage if it implements store.Counter
+	counter store.Counter
+	// healthChecker is the CRUD storage if it implements store.HealthChecker, see API.ServeHealth
+	healthChecker store.HealthChecker
+	// dryRunHeader makes the list handler only count objects for requests having this header
+	dryRunHeader string
+	// topLevelMeta holds the functions returning the top-level meta of the responses, by key
+	topLevelMeta map[string]func(ctx context.Context, r *http.Request) interface{}
+	// structuredLogger logs each request handled by the resource
+	structuredLogger StructuredLogger
+	// randomRedirect redirects random object requests to the object instead of sending it
+	randomRedirect bool
+	// errorCounts holds the errorCounter of each route, keyed by "<METHOD> <path>", in debug mode
+	errorCounts *sync.Map
+	// strictTypes rejects POST and PATCH bodies whose object type is not the resource type
+	strictTypes bool
+	// hooks are run before the storage of each CRUD operation
+	hooks CRUDHooks
+	// noOpUpdate defines the response of the patch handler when the update changes nothing
+	noOpUpdate NoOpBehaviour
+	// errorMapper converts the errors returned by the CRUD storages, see SetErrorMapper
+	errorMapper store.SQLErrorMapper
+	// beforeCreate, beforeUpdate, beforeFetch and beforeDelete are run before the CRUD storages
+	beforeCreate []BeforeObjectFunc
+	beforeUpdate []BeforeObjectFunc
+	beforeFetch  []BeforeIDFunc
+	beforeDelete []BeforeIDFunc
 }
 
 /*
@@ -82,14 +223,47 @@ managing routes and handling API calls.
 The prefix parameter causes all routes created within the resource to be prefixed.
 */
 func NewResource(resourceType string) *Resource {
-	return &Resource{
-		// Mux is a goji.SubMux, inherits context from parent Mux
-		Mux: goji.SubMux(),
+	resource := &Resource{
 		// Type of the resource, makes no assumptions about plurality
-		Type:          resourceType,
-		Relationships: map[string]Relationship{},
-		// A list of registered routes used for the OPTIONS HTTP method
-		Routes: []Route{},
+		Type:        resourceType,
+		strictTypes: true,
+	}
+	resource.reset()
+	return resource
+}
+
+// reset removes all the routes, relationships and middleware registered to the resource.
+func (res *Resource) reset() {
+	// Mux is a goji.SubMux, inherits context from parent Mux
+	res.Mux = goji.SubMux()
+	res.Relationships = map[string]Relationship{}
+	res.Actions = map[string]Route{}
+	// A list of registered routes used for the OPTIONS HTTP method
+	res.Routes = []Route{}
+	res.getStorage = nil
+	res.staticRoutes = nil
+	res.counter = nil
+
+	// recover from panics first so that resource middleware is covered as well
+	res.UseC(res.recoverMiddleware)
+	res.UseC(requestMetaMiddleware)
+	if res.permissionsExtractor != nil {
+		res.UseC(res.permissionsMiddleware)
+	}
+	if res.circuitBreaker != nil {
+		res.UseC(res.circuitBreakerMiddleware)
+	}
+	if res.readCache != nil {
+		res.UseC(res.readCacheMiddleware)
+	}
+	if res.topLevelMeta != nil {
+		res.UseC(res.topLevelMetaMiddleware)
+	}
+	if res.structuredLogger != nil {
+		res.UseC(res.structuredLoggingMiddleware)
+	}
+	if res.errorCounts != nil {
+		res.UseC(res.errorCountMiddleware)
 	}
 }
 
@@ -100,11 +274,40 @@ func NewCRUDResource(resourceType string, storage store.CRUD) *Resource {
 	return resource
 }
 
+/*
+NewCRUDResourceFromStruct generates a resource whose type is derived from the
+name of the model struct, lower cased and pluralized:
+
+	// registers a "users" resource
+	resource := jshapi.NewCRUDResourceFromStruct(userStorage, User{})
+
+Pluralization is naive, "es" is appended to names ending in "s", "s" otherwise.
+Use NewCRUDResource if the resource type needs to be specified explicitly.
+*/
+func NewCRUDResourceFromStruct(storage store.CRUD, model interface{}) *Resource {
+	return NewCRUDResource(resourceTypeOf(model), storage)
+}
+
+// resourceTypeOf returns the lower cased and pluralized struct name of the model.
+func resourceTypeOf(model interface{}) string {
+	modelType := reflect.TypeOf(model)
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+
+	name := strings.ToLower(modelType.Name())
+	if strings.HasSuffix(name, "s") {
+		return name + "es"
+	}
+	return name + "s"
+}
+
 /*
 CRUD is syntactic sugar and a shortcut for registering all JSON API CRUD
 routes for a compatible storage implementation:
 
 Registers handlers for:
+
 	GET    /resource
 	POST   /resource
 	GET    /resource/:id
@@ -118,20 +321,46 @@ func (res *Resource) CRUD(storage store.CRUD) {
 // PartialCRUD registers all CRUD routes with OPTIONS and HEAD support.
 // It provides a handler that sends a 405 response for methods contained in the disallow parameter.
 // Since GET is always allowed, the supported parameters are POST,PATCH,DELETE.
+//
+// If the storage implements store.CloudEventAuditor, an audit event is emitted after each mutation.
+// If it implements store.HealthChecker, it is pinged by the health check of the API, see API.ServeHealth.
+// If it implements store.DiffUpdater or store.TrackingUpdater, DiffUpdate or TrackingUpdate
+// is used instead of Update to patch objects.
 func (res *Resource) PartialCRUD(storage store.CRUD, disallow string) {
+	if auditor, ok := storage.(store.CloudEventAuditor); ok {
+		res.auditor = auditor
+	}
+	if counter, ok := storage.(store.Counter); ok {
+		res.counter = counter
+	}
+	if checker, ok := storage.(store.HealthChecker); ok {
+		res.healthChecker = checker
+	}
 	res.Options(patRoot)
 	res.List(storage.List, true)
 	res.Post(storage.Save, !strings.Contains(disallow, post))
 	res.Options(patID)
-	res.Get(storage.Get, true)
-	res.Patch(storage.Update, !strings.Contains(disallow, patch))
+	if getter, ok := storage.(store.FieldGetter); ok {
+		res.GetFields(storage.Get, getter.GetWithFields, true)
+	} else {
+		res.Get(storage.Get, true)
+	}
+	if updater, ok := storage.(store.DiffUpdater); ok {
+		res.PatchDiff(storage.Get, updater.DiffUpdate, !strings.Contains(disallow, patch))
+	} else if updater, ok := storage.(store.TrackingUpdater); ok {
+		res.PatchTracking(updater.TrackingUpdate, !strings.Contains(disallow, patch))
+	} else {
+		res.Patch(storage.Update, !strings.Contains(disallow, patch))
+	}
 	res.Delete(storage.Delete, !strings.Contains(disallow, delete))
+	res.logRouteOrder()
 }
 
 /*
 ToOne is syntactic sugar for registering all JSON API routes for a to-one relationship:
 
 Registers handlers for:
+
 	GET    /resource/:id/relationship
 	GET    /resource/:id/relationships/relationship
 	PATCH  /resource/:id/relationships/relationship
@@ -157,12 +386,14 @@ func (res *Resource) PartialToOne(relationship string, storage store.ToOne, disa
 	res.PatchOne(storage.Update, relationshipMatcher, !strings.Contains(disallow, patch))
 
 	res.Relationships[relationship] = ToOne
+	res.logRouteOrder()
 }
 
 /*
 ToMany is syntactic sugar for registering all JSON API routes for a to-many relationship:
 
 Registers handlers for:
+
 	GET    /resource/:id/relationship
 	GET    /resource/:id/relationships/relationship
 	PATCH  /resource/:id/relationships/relationship
@@ -189,11 +420,20 @@ func (res *Resource) PartialToMany(relationship string, storage store.ToMany, di
 	relationshipMatcher := fmt.Sprintf("%s/relationships/%s", patID, relationship)
 	res.Options(relationshipMatcher)
 	res.ListRelationships(storage.List, relationshipMatcher, true)
-	res.PostMany(storage.Save, relationshipMatcher, !strings.Contains(disallow, post))
-	res.PatchMany(storage.Update, relationshipMatcher, !strings.Contains(disallow, patch))
+	if creator, ok := storage.(store.ToManyCreator); ok {
+		res.PostManyCreate(creator.Create, relationshipMatcher, !strings.Contains(disallow, post))
+	} else {
+		res.PostMany(storage.Save, relationshipMatcher, !strings.Contains(disallow, post))
+	}
+	if replacer, ok := storage.(store.ToManyReplacer); ok {
+		res.PatchMany(replacer.Replace, relationshipMatcher, !strings.Contains(disallow, patch))
+	} else {
+		res.PatchMany(storage.Update, relationshipMatcher, !strings.Contains(disallow, patch))
+	}
 	res.DeleteMany(storage.Delete, relationshipMatcher, !strings.Contains(disallow, delete))
 
 	res.Relationships[relationship] = ToMany
+	res.logRouteOrder()
 }
 
 // Action adds to the resource a custom action of the form:
@@ -209,7 +449,229 @@ func (res *Resource) Action(action string, storage store.Action, allow bool) {
 	}
 
 	res.HandleFuncC(pat.Post(matcher), handler)
-	res.addRoute(post, matcher, allow)
+	res.addAction(action, post, matcher, allow)
+}
+
+// ListAction adds to the resource a custom action responding with a list, of the form:
+// POST /resources/:id/<action>
+func (res *Resource) ListAction(action string, storage store.ListAction, allow bool) {
+	matcher := path.Join(patID, action)
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.listActionHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addAction(action, post, matcher, allow)
+}
+
+/*
+IdempotentAction adds to the resource a custom action of the form:
+POST /resources/:id/<action>
+
+When the request has an Idempotency-Key header, the response is cached and sent
+again for any subsequent request with the same key, without calling storage.
+*/
+func (res *Resource) IdempotentAction(action string, storage store.Action, cache store.IdempotencyCache, allow bool) {
+	matcher := path.Join(patID, action)
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.idempotentActionHandler(ctx, w, r, action, storage, cache)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addAction(action, post, matcher, allow)
+}
+
+// RelationshipAction adds to the resource a custom action taking a resource linkage list as body:
+// POST /resources/:id/<action>
+func (res *Resource) RelationshipAction(action string, storage store.RelationshipAction, allow bool) {
+	matcher := path.Join(patID, action)
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.relationshipActionHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addAction(action, post, matcher, allow)
+}
+
+// Command adds to the resource a custom action that does not read the request body:
+// POST /resources/:id/<action>
+func (res *Resource) Command(action string, storage store.Command, allow bool) {
+	matcher := path.Join(patID, action)
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.commandHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addAction(action, post, matcher, allow)
+}
+
+/*
+StreamingAction adds to the resource a long-running custom action of the form:
+POST /resources/:id/<action>
+
+The response is a chunked application/x-ndjson stream written by storage, which should
+flush the response writer after each progress update. The SendHandler is not called:
+an error returned by storage is written as the last line of the stream.
+*/
+func (res *Resource) StreamingAction(action string, storage store.StreamingAction, allow bool) {
+	matcher := path.Join(patID, action)
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.streamingActionHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addAction(action, post, matcher, allow)
+}
+
+/*
+EnableSelfLinks adds links to the resource objects returned by the GET /resource and
+GET /resource/:id handlers. Each object gets a self link, and each registered
+relationship gets a links object as per the JSON API specification:
+
+	"relationships": {
+		"<relationship>": {
+			"links": {
+				"self": "/resources/:id/relationships/<relationship>",
+				"related": "/resources/:id/<relationship>"
+			}
+		}
+	}
+*/
+func (res *Resource) EnableSelfLinks() {
+	res.selfLinks = true
+}
+
+// SetEmptyListBehaviour defines how GET /resource responds when there is no object to list.
+// The default behaviour is Return200, as an empty list is a valid JSON API response.
+func (res *Resource) SetEmptyListBehaviour(behaviour EmptyListBehaviour) {
+	res.emptyList = behaviour
+}
+
+// SetClientIDHeader allows client generated IDs only for POST requests including a non-empty
+// value for the header, regardless of EnableClientGeneratedIDs.
+func (res *Resource) SetClientIDHeader(header string) {
+	res.clientIDHeader = header
+}
+
+// allowClientID returns true if the request may provide the ID of the object to create.
+func (res *Resource) allowClientID(r *http.Request) bool {
+	if res.clientIDHeader != "" {
+		return r.Header.Get(res.clientIDHeader) != ""
+	}
+	return EnableClientGeneratedIDs
+}
+
+// validateClientID returns a 400 error if the ID of the object to create is blank but not
+// empty, and a 403 error if it is set while client generated IDs are not allowed.
+// An empty ID, either missing or `"id": ""`, is treated as no ID.
+func (res *Resource) validateClientID(r *http.Request, object *jsh.Object) jsh.ErrorType {
+	if object.ID != "" && strings.TrimSpace(object.ID) == "" {
+		return jsh.BadRequestError("Invalid object ID", "ID must not be blank")
+	}
+	if !res.allowClientID(r) && object.ID != "" {
+		return jsh.ForbiddenError("Client-generated IDs are unsupported")
+	}
+	return nil
+}
+
+// SetCORS defines whether OPTIONS responses include an `Access-Control-Allow-Methods` header
+// equal to the Allow header, so that CORS middleware handling the other preflight headers
+// does not have to compute the methods allowed by each route.
+func (res *Resource) SetCORS(enabled bool) {
+	res.cors = enabled
+}
+
+/*
+SetDryRunHeader defines a header making list requests only count the objects the list
+would contain, responding with `{"meta": {"count": <count>}, "data": []}`. Objects are
+counted with the store.Counter of the resource, registered with Count or implemented by
+its CRUD storage. Dry runs never list the objects: without counter, a 501 error is sent.
+An empty header disables dry runs.
+*/
+func (res *Resource) SetDryRunHeader(header string) {
+	res.dryRunHeader = header
+}
+
+// SetParameterWarnings defines whether list responses include a `Warning: 199 - "Unrecognised parameter: <param>"`
+// header for each query parameter ignored by the resource, i.e. not page[*], filter[*], sort, include or fields[*].
+func (res *Resource) SetParameterWarnings(enabled bool) {
+	res.parameterWarnings = enabled
+}
+
+/*
+SetFilterSchema defines the type of the filterable fields of the resource, one of
+"string", "int", "bool" or "time". The list handler parses the `filter[<field>]` query
+parameters of these fields and stores them in the context as a store.TypedFilterMap,
+see store.TypedFiltersFromContext. A 400 error is sent if a value has an invalid type.
+*/
+func (res *Resource) SetFilterSchema(schema map[string]string) {
+	res.filterSchema = schema
+}
+
+// SetStrictTypeChecking defines whether POST and PATCH requests whose object type is not
+// the resource type are rejected with a 409 error, which is the default.
+func (res *Resource) SetStrictTypeChecking(enabled bool) {
+	res.strictTypes = enabled
+}
+
+// validateType returns a 409 error if strict type checking is enabled and the type of
+// the object sent as body is not the resource type.
+func (res *Resource) validateType(object *jsh.Object) jsh.ErrorType {
+	if res.strictTypes && object.Type != res.Type {
+		return jsh.ConflictError(object.Type, "")
+	}
+	return nil
+}
+
+/*
+SetErrorMapper registers a function converting the errors returned by the CRUD storages of
+the resource before they are sent, so that storages can return database/sql errors wrapped
+in a jsh.ErrorType instead of converting them:
+
+	resource.SetErrorMapper(store.DefaultSQLErrorMapper)
+
+Errors the mapper returns nil for are sent unchanged.
+*/
+func (res *Resource) SetErrorMapper(mapper store.SQLErrorMapper) {
+	res.errorMapper = mapper
+}
+
+// mapError converts a storage error with the error mapper of the resource, if any.
+func (res *Resource) mapError(err jsh.ErrorType) jsh.ErrorType {
+	if res.errorMapper == nil || err == nil || reflect.ValueOf(err).IsNil() {
+		return err
+	}
+	if mapped := res.errorMapper(err); mapped != nil {
+		return mapped
+	}
+	return err
+}
+
+// SetCacheBustOnMutation defines whether successful save, update and delete responses
+// include the `Clear-Site-Data: "cache"` and `Cache-Control: no-store` headers, so that
+// browser caches and CDN edges invalidate their copies of the resource.
+func (res *Resource) SetCacheBustOnMutation(enabled bool) {
+	res.cacheBust = enabled
 }
 
 // Options registers a `OPTIONS /resource` handler for the resource.
@@ -237,165 +699,509 @@ func (res *Resource) Post(storage store.Save, allow bool) {
 	res.addRoute(post, patRoot, allow)
 }
 
-// Get registers a `GET /resource/:id` handler for the resource.
-func (res *Resource) Get(storage store.Get, allow bool) {
+// AsyncPost registers a `POST /resource` handler for the resource supporting asynchronous creation.
+// When storage returns a job URL, it responds with 202 Accepted, a `Location: <job-url>` header and
+// the object with a "pending" meta status, instead of 201 Created. If storage returns no object along
+// with the job URL, the 202 response only has the Location header.
+func (res *Resource) AsyncPost(storage store.AsyncSave, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.fetchHandler(ctx, w, r, storage)
+			res.asyncPostHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Get(patID), handler)
-	res.addRoute(head, patID, allow)
-	res.addRoute(get, patID, allow)
+	res.HandleFuncC(pat.Post(patRoot), handler)
+	res.addRoute(post, patRoot, allow)
 }
 
-// List registers a `GET /resource` handler for the resource.
-func (res *Resource) List(storage store.List, allow bool) {
+// DryRunHeader is the header of the validate only POST requests, see ValidatedPost.
+const DryRunHeader = "X-Dry-Run"
+
+// ValidatedPost registers a `POST /resource` handler for the resource supporting validate only
+// requests. When the request has a `X-Dry-Run: true` header, storage is called with dryRun set
+// and the object as it would be saved is sent with a 200 response, instead of 201 Created.
+func (res *Resource) ValidatedPost(storage store.DryRunSave, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.listHandler(ctx, w, r, storage)
+			if r.Header.Get(DryRunHeader) == "true" {
+				res.dryRunPostHandler(ctx, w, r, storage)
+				return
+			}
+			res.postHandler(ctx, w, r, func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+				return storage(ctx, object, false)
+			})
 		}
 	}
 
-	res.HandleFuncC(pat.Get(patRoot), handler)
-	res.addRoute(head, patRoot, allow)
-	res.addRoute(get, patRoot, allow)
+	res.HandleFuncC(pat.Post(patRoot), handler)
+	res.addRoute(post, patRoot, allow)
 }
 
-// Patch registers a `PATCH /resource/:id` handler for the resource.
-func (res *Resource) Patch(storage store.Update, allow bool) {
+// Get registers a `GET /resource/:id` handler for the resource, along with a
+// `HEAD /resource/:id` handler sending the headers of the fetch response without its body.
+func (res *Resource) Get(storage store.Get, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.patchHandler(ctx, w, r, storage)
+			res.fetchHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Patch(patID), handler)
-	res.addRoute(patch, patID, allow)
-}
-
-// Delete registers a `DELETE /resource/:id` handler for the resource.
-func (res *Resource) Delete(storage store.Delete, allow bool) {
-	var handler = res.notAllowedHandler
+	var headHandler = res.notAllowedHandler
 	if allow {
-		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.deleteHandler(ctx, w, r, storage)
+		headHandler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.headHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Delete(patID), handler)
-	res.addRoute(delete, patID, allow)
+	res.getStorage = storage
+	// pat.Get matches HEAD requests as well, the HEAD route must be registered first
+	res.HandleFuncC(res.idPattern(pat.Head(patID)), headHandler)
+	res.HandleFuncC(res.idPattern(pat.Get(patID)), handler)
+	res.addRoute(head, patID, allow)
+	res.addRoute(get, patID, allow)
 }
 
-// ToOne relationship
+// GetFields registers a `GET /resource/:id` handler for the resource that fetches the
+// object with storage when the request has a sparse fieldset for the resource type,
+// `fields[<type>]=<field>,<field>`, and with get otherwise.
+func (res *Resource) GetFields(get store.Get, storage store.GetWithFields, allow bool) {
+	res.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		if fields, ok := store.ProjectionHintFromContext(ctx); ok {
+			return storage(ctx, id, fields)
+		}
+		return get(ctx, id)
+	}, allow)
+}
 
-// GetRelated registers a `GET /resources/:id/<relationship>` handler for the resource relationship.
-func (res *Resource) GetRelated(storage store.Get, matcher string, allow bool) {
+/*
+List registers a `GET /resource` handler for the resource.
+
+Keyset pagination is requested with `?page[after]=<cursor>`, the decoded cursor is
+passed to the storage as a store.SearchAfterCursor under store.SearchAfterKey, and the
+response document carries a `links.next` member with the cursor of the last object.
+An empty `page[after]` requests the first page.
+*/
+func (res *Resource) List(storage store.List, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.fetchHandler(ctx, w, r, storage)
+			res.listHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Get(matcher), handler)
-	res.addRoute(head, matcher, allow)
-	res.addRoute(get, matcher, allow)
+	res.HandleFuncC(pat.Get(patRoot), handler)
+	res.addRoute(head, patRoot, allow)
+	res.addRoute(get, patRoot, allow)
 }
 
-// GetRelationship registers a `GET /resources/:id/relationships/<relationship>` handler for the resource relationship.
-func (res *Resource) GetRelationship(storage store.ToOneGet, matcher string, allow bool) {
+// Count registers a `GET /resource/count` handler for the resource, responding with the
+// number of objects in the meta of the response, as "count".
+func (res *Resource) Count(storage store.Counter, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.fetchIDHandler(ctx, w, r, storage)
+			res.countHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Get(matcher), handler)
-	res.addRoute(head, matcher, allow)
-	res.addRoute(get, matcher, allow)
+	res.counter = storage
+	res.handleStatic(patCount, handler)
+	res.addRoute(head, patCount, allow)
+	res.addRoute(get, patCount, allow)
 }
 
-// PatchOne registers a `PATCH /resources/:id/relationships/<relationship>` handler for the resource relationship.
-func (res *Resource) PatchOne(storage store.ToOneUpdate, matcher string, allow bool) {
+// Random registers a `GET /resource/random` handler for the resource, sending a random object.
+func (res *Resource) Random(storage store.RandomGet, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.patchOneHandler(ctx, w, r, storage)
+			res.randomHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Patch(matcher), handler)
-	res.addRoute(patch, matcher, allow)
+	res.handleStatic(patRandom, handler)
+	res.addRoute(head, patRandom, allow)
+	res.addRoute(get, patRandom, allow)
 }
 
-// ToMany relationship
+// SetRandomRedirect defines whether `GET /resource/random` responds with a 303 redirect to
+// `/resource/<id>` instead of the random object.
+func (res *Resource) SetRandomRedirect(enabled bool) {
+	res.randomRedirect = enabled
+}
 
-// ListRelated registers a `GET /resources/:id/<relationship>` handler for the resource relationships.
-func (res *Resource) ListRelated(storage store.ToManyListResources, matcher string, allow bool) {
+/*
+ListWithIncludes registers a `GET /resource` handler for the resource whose storage returns
+the related objects of the list along with it, so that they are sent in the "included"
+member of the response without further queries. When the request has an
+`include=<relationship>,<relationship>` query parameter, only the objects of these
+relationships are included.
+*/
+func (res *Resource) ListWithIncludes(storage store.ListWithRelationships, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.listManyHandler(ctx, w, r, storage)
+			included := map[string]jsh.List{}
+			ctx = context.WithValue(ctx, includedKey, included)
+			res.listHandler(ctx, w, r, func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+				list, related, err := storage(ctx)
+				for name, objects := range related {
+					included[name] = objects
+				}
+				return list, err
+			})
 		}
 	}
 
-	res.HandleFuncC(pat.Get(matcher), handler)
-	res.addRoute(head, matcher, allow)
-	res.addRoute(get, matcher, allow)
+	res.HandleFuncC(pat.Get(patRoot), handler)
+	res.addRoute(head, patRoot, allow)
+	res.addRoute(get, patRoot, allow)
 }
 
-// ListRelationships registers a `GET /resources/:id/relationships/<relationship>` handler for the resource relationships.
-func (res *Resource) ListRelationships(storage store.ToManyList, matcher string, allow bool) {
+// Search registers a `GET /resource/search?q=<query>&filter[<field>]=<value>` handler for the resource.
+func (res *Resource) Search(storage store.Search, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.listIDHandler(ctx, w, r, storage)
+			res.searchHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Get(matcher), handler)
-	res.addRoute(head, matcher, allow)
-	res.addRoute(get, matcher, allow)
+	res.handleStatic(patSearch, handler)
+	res.addRoute(head, patSearch, allow)
+	res.addRoute(get, patSearch, allow)
 }
 
-// PatchMany registers a `PATCH /resources/:id/relationships/<relationship>` handler for the resource relationships.
-func (res *Resource) PatchMany(storage store.ToManyUpdate, matcher string, allow bool) {
+/*
+FilteredList registers a `GET /resource` handler for the resource passing the
+`filter[<field>]=<value>` and `sort=<field>,-<field>` query parameters to storage as a
+store.QueryParams. A 400 error is sent without calling storage if a sort field is empty
+or malformed, e.g. `sort=name,,-date` or `sort=--name`.
+*/
+func (res *Resource) FilteredList(storage store.FilteredList, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.patchManyHandler(ctx, w, r, storage)
+			res.filteredListHandler(ctx, w, r, storage)
 		}
 	}
 
-	res.HandleFuncC(pat.Patch(matcher), handler)
-	res.addRoute(patch, matcher, allow)
+	res.HandleFuncC(pat.Get(patRoot), handler)
+	res.addRoute(head, patRoot, allow)
+	res.addRoute(get, patRoot, allow)
 }
 
-// PostMany registers a `POST /resources/:id/relationships/<relationship>` handler for the resource relationships.
-func (res *Resource) PostMany(storage store.ToManyUpdate, matcher string, allow bool) {
+/*
+ChunkedList registers a `GET /resource` handler for the resource streaming the list
+returned by storage a chunk of chunkSize objects at a time, until storage reports that
+no more chunks are available.
+
+The response is a chunked application/x-ndjson stream, each line being a partial JSON:API
+document holding the objects of a chunk, that the client must reassemble. The SendHandler
+is not called: an error returned by storage is written as the last line of the stream.
+*/
+func (res *Resource) ChunkedList(storage store.ChunkedList, chunkSize int, allow bool) {
 	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.updateManyHandler(ctx, w, r, storage)
+			res.chunkedListHandler(ctx, w, r, storage, chunkSize)
 		}
 	}
 
-	res.HandleFuncC(pat.Post(matcher), handler)
-	res.addRoute(post, matcher, allow)
+	res.HandleFuncC(pat.Get(patRoot), handler)
+	res.addRoute(head, patRoot, allow)
+	res.addRoute(get, patRoot, allow)
 }
 
-// DeleteMany registers a `DELETE /resources/:id/relationships/<relationship>` handler for the resource relationships.
-func (res *Resource) DeleteMany(storage store.ToManyUpdate, matcher string, allow bool) {
-	var handler = res.notAllowedHandler
+// Patch registers a `PATCH /resource/:id` handler for the resource.
+// It responds with 200 and the object returned by storage, or 204 if storage returns nil.
+func (res *Resource) Patch(storage store.Update, allow bool) {
+	var handler = res.notAllowedHandler
 	if allow {
 		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.updateManyHandler(ctx, w, r, storage)
+			res.patchHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Patch(patID), handler)
+	res.addRoute(patch, patID, allow)
+}
+
+// PatchDiff registers a `PATCH /resource/:id` handler for the resource that fetches
+// the current state of the object before passing it to storage along with the patched object.
+func (res *Resource) PatchDiff(get store.Get, storage store.DiffUpdate, allow bool) {
+	res.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		old, err := get(ctx, object.ID)
+		if err != nil && reflect.ValueOf(err).IsNil() == false {
+			return nil, err
+		}
+		return storage(ctx, old, object)
+	}, allow)
+}
+
+// PatchTracking registers a `PATCH /resource/:id` handler for the resource that adds
+// the fields changed by storage to the meta of the response, as "changed_fields".
+func (res *Resource) PatchTracking(storage store.TrackingUpdate, allow bool) {
+	res.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		updated, changed, err := storage(ctx, object)
+		if updated != nil && changed != nil {
+			if updated.Meta == nil {
+				updated.Meta = map[string]interface{}{}
+			}
+			updated.Meta["changed_fields"] = changed
+		}
+		return updated, err
+	}, allow)
+}
+
+// Delete registers a `DELETE /resource/:id` handler for the resource.
+func (res *Resource) Delete(storage store.Delete, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.deleteHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Delete(patID), handler)
+	res.addRoute(delete, patID, allow)
+}
+
+// IdempotentDelete registers a `DELETE /resource/:id` handler for the resource that responds
+// with 204 if the object existed and 404 otherwise, so that clients can tell whether it was deleted.
+func (res *Resource) IdempotentDelete(storage store.IdempotentDelete, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.idempotentDeleteHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Delete(patID), handler)
+	res.addRoute(delete, patID, allow)
+}
+
+// BulkDelete registers a `DELETE /resource` handler for the resource, deleting the objects
+// of the resource linkage list sent as body. It responds with 204 if all of them were deleted.
+func (res *Resource) BulkDelete(storage store.BulkDelete, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.bulkDeleteHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Delete(patRoot), handler)
+	res.addRoute(delete, patRoot, allow)
+}
+
+// DeleteWithBody registers a `DELETE /resource/:id` handler for the resource that responds
+// with 200 and the deleted object instead of 204. The object is fetched before deletion,
+// which is not attempted if fetching fails.
+func (res *Resource) DeleteWithBody(storage store.CRUD, allow bool) {
+	if auditor, ok := storage.(store.CloudEventAuditor); ok {
+		res.auditor = auditor
+	}
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.deleteWithBodyHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Delete(patID), handler)
+	res.addRoute(delete, patID, allow)
+}
+
+// DeleteWithReason registers a `DELETE /resource/:id?reason=<reason>` handler for the resource.
+// The reason query parameter is passed to storage, see SetDeleteReasonRequired to make it mandatory.
+func (res *Resource) DeleteWithReason(storage store.DeleteWithReason, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.deleteWithReasonHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Delete(patID), handler)
+	res.addRoute(delete, patID, allow)
+}
+
+/*
+SetDeleteRequiresGet defines whether the delete handler fetches the object using the
+storage registered with Get before deleting it. If fetching fails, for instance with a 404
+because the object is already gone, the error is sent and the deletion is not attempted.
+
+This prevents 204 responses for objects that do not exist, at the cost of an extra
+storage call for each deletion.
+*/
+func (res *Resource) SetDeleteRequiresGet(enabled bool) {
+	res.deleteRequiresGet = enabled
+}
+
+// SetDeleteReasonRequired defines whether a DeleteWithReason handler responds
+// with a 400 error when the reason query parameter is missing.
+func (res *Resource) SetDeleteReasonRequired(required bool) {
+	res.deleteReasonRequired = required
+}
+
+// ToOne relationship
+
+// GetRelated registers a `GET /resources/:id/<relationship>` handler for the resource relationship.
+func (res *Resource) GetRelated(storage store.Get, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.fetchHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Get(matcher), handler)
+	res.addRoute(head, matcher, allow)
+	res.addRoute(get, matcher, allow)
+}
+
+// GetRelationship registers a `GET /resources/:id/relationships/<relationship>` handler for the resource relationship.
+func (res *Resource) GetRelationship(storage store.ToOneGet, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.fetchIDHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Get(matcher), handler)
+	res.addRoute(head, matcher, allow)
+	res.addRoute(get, matcher, allow)
+}
+
+// PatchOne registers a `PATCH /resources/:id/relationships/<relationship>` handler for the resource relationship.
+func (res *Resource) PatchOne(storage store.ToOneUpdate, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.patchOneHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Patch(matcher), handler)
+	res.addRoute(patch, matcher, allow)
+}
+
+// ToMany relationship
+
+// ListRelated registers a `GET /resources/:id/<relationship>` handler for the resource relationships.
+func (res *Resource) ListRelated(storage store.ToManyListResources, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.listManyHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Get(matcher), handler)
+	res.addRoute(head, matcher, allow)
+	res.addRoute(get, matcher, allow)
+}
+
+/*
+DynamicToMany registers a `GET /resources/:id/<pattern>` handler for a to-many relationship
+whose route has a parameter segment, such as "tags/:tagType":
+
+	GET /users/:id/tags/system
+	GET /users/:id/tags/custom
+
+The value of the parameter segment is passed to storage along with the id of the resource.
+*/
+func (res *Resource) DynamicToMany(pattern string, storage store.DynamicToMany, allow bool) {
+	matcher := path.Join(patID, pattern)
+
+	var param string
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, ":") {
+			param = strings.TrimPrefix(segment, ":")
+		}
+	}
+
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.dynamicManyHandler(ctx, w, r, param, storage)
+		}
+	}
+
+	res.Options(matcher)
+	res.HandleFuncC(pat.Get(matcher), handler)
+	res.addRoute(head, matcher, allow)
+	res.addRoute(get, matcher, allow)
+}
+
+// ListRelationships registers a `GET /resources/:id/relationships/<relationship>` handler for the resource relationships.
+func (res *Resource) ListRelationships(storage store.ToManyList, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.listIDHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Get(matcher), handler)
+	res.addRoute(head, matcher, allow)
+	res.addRoute(get, matcher, allow)
+}
+
+// PatchMany registers a `PATCH /resources/:id/relationships/<relationship>` handler for the resource relationships.
+// It responds with 200 and the list returned by storage, which should be the full relationship list
+// when it changed beyond the request, or 204 if storage returns nil.
+func (res *Resource) PatchMany(storage store.ToManyUpdate, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.patchManyHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Patch(matcher), handler)
+	res.addRoute(patch, matcher, allow)
+}
+
+// PostMany registers a `POST /resources/:id/relationships/<relationship>` handler for the resource relationships.
+func (res *Resource) PostMany(storage store.ToManyUpdate, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.updateManyHandler(ctx, w, r, store.ToManyAdd, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addRoute(post, matcher, allow)
+}
+
+// PostManyCreate registers a `POST /resources/:id/relationships/<relationship>` handler for the resource relationships
+// that responds with 201 when storage created the relationships and 200 otherwise.
+func (res *Resource) PostManyCreate(storage store.ToManyCreate, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.createManyHandler(ctx, w, r, storage)
+		}
+	}
+
+	res.HandleFuncC(pat.Post(matcher), handler)
+	res.addRoute(post, matcher, allow)
+}
+
+// DeleteMany registers a `DELETE /resources/:id/relationships/<relationship>` handler for the resource relationships.
+func (res *Resource) DeleteMany(storage store.ToManyUpdate, matcher string, allow bool) {
+	var handler = res.notAllowedHandler
+	if allow {
+		handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.updateManyHandler(ctx, w, r, store.ToManyRemove, storage)
 		}
 	}
 
@@ -412,96 +1218,778 @@ func (res *Resource) notAllowedHandler(ctx context.Context, w http.ResponseWrite
 
 // OPTIONS
 func (res *Resource) optionsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Allow", res.allowHeader(ctx, r))
+	allow := res.allowHeader(ctx, r)
+	w.Header().Add("Allow", allow)
+	if res.cors {
+		w.Header().Set("Access-Control-Allow-Methods", allow)
+	}
 	w.Header().Add("Content-Type", jsh.ContentType)
+	// let caches know the response depends on content negotiation
+	w.Header().Add("Vary", "Accept, Content-Type")
+	if VaryAcceptEncoding {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// POST /resources
-func (res *Resource) postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Save) {
+// parsePost runs the pre-save hooks, then parses and validates the object of a POST request
+// before running the before create hooks on it.
+func (res *Resource) parsePost(ctx context.Context, r *http.Request) (context.Context, *jsh.Object, jsh.ErrorType) {
+	ctx, hookErr := runHooks(ctx, r, res.hooks.PreSave)
+	if hookErr != nil {
+		return ctx, nil, hookErr
+	}
+	parsedObject, parseErr := jsh.ParseObject(r)
+	if parseErr != nil && reflect.ValueOf(parseErr).IsNil() == false {
+		return ctx, nil, parseErr
+	}
+
+	if err := res.validateType(parsedObject); err != nil {
+		return ctx, nil, err
+	}
+
+	if err := res.validateClientID(r, parsedObject); err != nil {
+		return ctx, nil, err
+	}
+
+	parsedObject, beforeErr := runBeforeObject(ctx, parsedObject, res.beforeCreate)
+	if beforeErr != nil {
+		return ctx, nil, beforeErr
+	}
+	return ctx, parsedObject, nil
+}
+
+// POST /resources
+func (res *Resource) postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Save) {
+	ctx, parsedObject, parseErr := res.parsePost(ctx, r)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	if res.clientClosed(ctx, w, r) {
+		return
+	}
+
+	start := time.Now()
+	object, err := storage(ctx, parsedObject)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	res.emitEvent(ctx, r, store.EventSaved, "", object)
+	res.addCacheBustHeaders(w)
+	SendHandler(ctx, w, r, object)
+}
+
+// POST /resources for asynchronous creation
+func (res *Resource) asyncPostHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.AsyncSave) {
+	ctx, parsedObject, parseErr := res.parsePost(ctx, r)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	start := time.Now()
+	object, jobURL, err := storage(ctx, parsedObject)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if jobURL == "" {
+		res.emitEvent(ctx, r, store.EventSaved, "", object)
+		res.addCacheBustHeaders(w)
+		SendHandler(ctx, w, r, object)
+		return
+	}
+
+	w.Header().Set("Location", jobURL)
+	if object == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if object.Meta == nil {
+		object.Meta = map[string]interface{}{}
+	}
+	object.Meta["status"] = "pending"
+	object.Status = http.StatusAccepted
+	SendHandler(ctx, w, r, object)
+}
+
+// POST /resources with the X-Dry-Run header
+func (res *Resource) dryRunPostHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.DryRunSave) {
+	ctx, parsedObject, parseErr := res.parsePost(ctx, r)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	start := time.Now()
+	object, err := storage(ctx, parsedObject, true)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if object != nil {
+		object.Status = http.StatusOK
+	}
+	SendHandler(ctx, w, r, object)
+}
+
+// GET /resources/:id
+func (res *Resource) fetchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Get) {
+	push := res.canPush(w)
+	w = res.readOnly(w)
+	ctx, hookErr := runHooks(ctx, r, res.hooks.PreGet)
+	if hookErr != nil {
+		SendHandler(ctx, w, r, hookErr)
+		return
+	}
+	id := pat.Param(ctx, "id")
+	ctx, id, idErr := res.parseID(ctx, id)
+	if idErr != nil {
+		SendHandler(ctx, w, r, idErr)
+		return
+	}
+	if fields := parseFieldSet(r.URL.Query(), res.Type); fields != nil {
+		ctx = context.WithValue(ctx, store.ProjectionHintKey, store.ProjectionHint(fields))
+	}
+
+	if beforeErr := runBeforeID(ctx, id, res.beforeFetch); beforeErr != nil {
+		SendHandler(ctx, w, r, beforeErr)
+		return
+	}
+
+	if res.clientClosed(ctx, w, r) {
+		return
+	}
+
+	start := time.Now()
+	object, err := res.cachedGet(ctx, storage)(ctx, id)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	res.addLinks(object)
+	if push {
+		res.addPreloadHints(w, r)
+	}
+	SendHandler(ctx, w, r, object)
+}
+
+// GET /resources
+func (res *Resource) listHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.List) {
+	w = res.readOnly(w)
+	ctx, hookErr := runHooks(ctx, r, res.hooks.PreList)
+	if hookErr != nil {
+		SendHandler(ctx, w, r, hookErr)
+		return
+	}
+	ctx = withListQuery(ctx, r.URL.Query(), res.Type)
+	if fields := parseFieldSet(r.URL.Query(), res.Type); fields != nil {
+		ctx = context.WithValue(ctx, store.ProjectionHintKey, store.ProjectionHint(fields))
+	}
+	if res.parameterWarnings {
+		for _, param := range unrecognisedParameters(r.URL.Query()) {
+			w.Header().Add("Warning", fmt.Sprintf("199 - \"Unrecognised parameter: %s\"", param))
+		}
+	}
+	if res.filterSchema != nil {
+		filters, parseErr := parseTypedFilters(r.URL.Query(), res.filterSchema)
+		if parseErr != nil {
+			SendHandler(ctx, w, r, parseErr)
+			return
+		}
+		ctx = context.WithValue(ctx, store.TypedFiltersKey, filters)
+	}
+	_, searchAfter := r.URL.Query()["page[after]"]
+	if searchAfter {
+		cursor, parseErr := parseSearchAfter(r.URL.Query().Get("page[after]"))
+		if parseErr != nil {
+			SendHandler(ctx, w, r, parseErr)
+			return
+		}
+		if cursor != nil {
+			ctx = context.WithValue(ctx, store.SearchAfterKey, cursor)
+		}
+	}
+
+	if res.clientClosed(ctx, w, r) {
+		return
+	}
+
+	if res.dryRunHeader != "" && r.Header.Get(res.dryRunHeader) != "" {
+		res.dryRunListHandler(ctx, w, r)
+		return
+	}
+
+	start := time.Now()
+	list, err := res.cachedList(ctx, r, storage)(ctx)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		if res.emptyList != Return200 || err.StatusCode() != http.StatusNotFound {
+			SendHandler(ctx, w, r, err)
+			return
+		}
+		list = nil
+	}
+
+	if len(list) == 0 {
+		switch res.emptyList {
+		case Return200:
+			list = jsh.List{}
+		case Return404:
+			SendHandler(ctx, w, r, &jsh.Error{
+				Title:  "Not Found",
+				Detail: fmt.Sprintf("No resource of type '%s' exists", res.Type),
+				Status: http.StatusNotFound,
+			})
+			return
+		}
+	}
+
+	for _, object := range list {
+		res.addLinks(object)
+	}
+	doc := jsh.Build(list)
+	if included, ok := ctx.Value(includedKey).(map[string]jsh.List); ok && len(list) > 0 {
+		doc.Included = includedObjects(included, r.URL.Query())
+	}
+	if cursor := nextSearchAfter(list, r.URL.Query()); searchAfter && cursor != nil {
+		query := r.URL.Query()
+		query.Set("page[after]", encodeSearchAfter(cursor))
+		sendWithLinks(ctx, w, r, doc, map[string]string{"next": r.URL.Path + "?" + query.Encode()})
+		return
+	}
+	SendHandler(ctx, w, r, doc)
+}
+
+// GET /resources/count
+func (res *Resource) countHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Counter) {
+	w = res.readOnly(w)
+
+	start := time.Now()
+	count, err := storage.Count(ctx)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	SendHandler(ctx, w, r, metaDocument(map[string]interface{}{"count": count}))
+}
+
+// GET /resources with the dry run header
+func (res *Resource) dryRunListHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if res.counter == nil {
+		err := jsh.NotImplemented(fmt.Sprintf("Dry run of resource '%s' without counter", res.Type))
+		err.Detail = fmt.Sprintf("Dry runs are not supported by resource '%s'", res.Type)
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	start := time.Now()
+	count, err := res.counter.Count(ctx)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	doc := jsh.Build(jsh.List{})
+	doc.Meta = map[string]interface{}{"count": count}
+	SendHandler(ctx, w, r, doc)
+}
+
+// GET /resources/random
+func (res *Resource) randomHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.RandomGet) {
+	w = res.readOnly(w)
+
+	start := time.Now()
+	object, err := storage(ctx)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if res.randomRedirect && object != nil {
+		w.Header().Set("Location", path.Join(path.Dir(r.URL.Path), object.ID))
+		w.WriteHeader(http.StatusSeeOther)
+		return
+	}
+
+	res.addLinks(object)
+	SendHandler(ctx, w, r, object)
+}
+
+// GET /resources/search
+func (res *Resource) searchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Search) {
+	query := r.URL.Query()
+
+	start := time.Now()
+	list, err := storage(ctx, query.Get("q"), parseFilters(query))
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if list == nil {
+		list = jsh.List{}
+	}
+	for _, object := range list {
+		res.addLinks(object)
+	}
+	SendHandler(ctx, w, r, list)
+}
+
+// GET /resources?filter[<field>]=<value>&sort=<field>
+func (res *Resource) filteredListHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.FilteredList) {
+	w = res.readOnly(w)
+	ctx = withListQuery(ctx, r.URL.Query(), res.Type)
+	params, parseErr := parseQueryParams(r.URL.Query())
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	start := time.Now()
+	list, err := storage(ctx, params)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if list == nil {
+		list = jsh.List{}
+	}
+	for _, object := range list {
+		res.addLinks(object)
+	}
+	SendHandler(ctx, w, r, list)
+}
+
+// PATCH /resources/:id
+func (res *Resource) patchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Update) {
+	ctx, hookErr := runHooks(ctx, r, res.hooks.PreUpdate)
+	if hookErr != nil {
+		SendHandler(ctx, w, r, hookErr)
+		return
+	}
 	parsedObject, parseErr := jsh.ParseObject(r)
 	if parseErr != nil && reflect.ValueOf(parseErr).IsNil() == false {
 		SendHandler(ctx, w, r, parseErr)
 		return
 	}
 
-	if !EnableClientGeneratedIDs && parsedObject.ID != "" {
-		SendHandler(ctx, w, r, jsh.ForbiddenError("Client-generated IDs are unsupported"))
+	id := pat.Param(ctx, "id")
+	if err := ValidateIDMatch(id, parsedObject); err != nil {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if err := res.validateType(parsedObject); err != nil {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	ctx, id, idErr := res.parseID(ctx, id)
+	if idErr != nil {
+		SendHandler(ctx, w, r, idErr)
+		return
+	}
+	parsedObject.ID = id
+
+	parsedObject, beforeErr := runBeforeObject(ctx, parsedObject, res.beforeUpdate)
+	if beforeErr != nil {
+		SendHandler(ctx, w, r, beforeErr)
+		return
+	}
+
+	if res.clientClosed(ctx, w, r) {
 		return
 	}
 
+	if res.noOpUpdate != CallStorageAnyway && res.getStorage != nil {
+		current, err := res.getStorage(ctx, id)
+		err = res.mapError(err)
+		if err != nil && reflect.ValueOf(err).IsNil() == false {
+			SendHandler(ctx, w, r, err)
+			return
+		}
+		if isNoOpUpdate(current, parsedObject) {
+			if res.noOpUpdate == Return204 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			current.Status = http.StatusOK
+			SendHandler(ctx, w, r, current)
+			return
+		}
+	}
+
+	start := time.Now()
 	object, err := storage(ctx, parsedObject)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
 	}
 
+	// storage may return nil when the object has not changed
+	var data interface{} = parsedObject
+	if object != nil {
+		data = object
+	}
+	res.emitEvent(ctx, r, store.EventUpdated, id, data)
+	res.addCacheBustHeaders(w)
+
+	// the client already has the current state of the object
+	if object == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	object.Status = http.StatusOK
 	SendHandler(ctx, w, r, object)
 }
 
-// GET /resources/:id
-func (res *Resource) fetchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Get) {
+// DELETE /resources/:id
+func (res *Resource) deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Delete) {
+	ctx, hookErr := runHooks(ctx, r, res.hooks.PreDelete)
+	if hookErr != nil {
+		SendHandler(ctx, w, r, hookErr)
+		return
+	}
 	id := pat.Param(ctx, "id")
+	ctx, id, idErr := res.parseID(ctx, id)
+	if idErr != nil {
+		SendHandler(ctx, w, r, idErr)
+		return
+	}
 
-	object, err := storage(ctx, id)
+	if beforeErr := runBeforeID(ctx, id, res.beforeDelete); beforeErr != nil {
+		SendHandler(ctx, w, r, beforeErr)
+		return
+	}
+
+	if res.clientClosed(ctx, w, r) {
+		return
+	}
+
+	start := time.Now()
+	if res.deleteRequiresGet && res.getStorage != nil {
+		if _, err := res.getStorage(ctx, id); err != nil && reflect.ValueOf(err).IsNil() == false {
+			res.recordTiming(ctx, r, start)
+			err = res.mapError(err)
+			SendHandler(ctx, w, r, err)
+			return
+		}
+	}
+
+	err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
+	err = res.mapError(err)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
 	}
 
-	SendHandler(ctx, w, r, object)
+	res.emitEvent(ctx, r, store.EventDeleted, id, jsh.NewIDObject(res.Type, id))
+	res.addCacheBustHeaders(w)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// GET /resources
-func (res *Resource) listHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.List) {
-	list, err := storage(ctx)
+// DELETE /resources/:id reporting whether the object existed
+func (res *Resource) idempotentDeleteHandler(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, storage store.IdempotentDelete) {
+	id := pat.Param(ctx, "id")
+
+	start := time.Now()
+	existed, err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
 	}
 
-	SendHandler(ctx, w, r, list)
+	if !existed {
+		SendHandler(ctx, w, r, jsh.NotFound(res.Type, id))
+		return
+	}
+
+	res.emitEvent(ctx, r, store.EventDeleted, id, jsh.NewIDObject(res.Type, id))
+	res.addCacheBustHeaders(w)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// PATCH /resources/:id
-func (res *Resource) patchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Update) {
-	parsedObject, parseErr := jsh.ParseObject(r)
-	if parseErr != nil && reflect.ValueOf(parseErr).IsNil() == false {
+// DELETE /resources
+func (res *Resource) bulkDeleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.BulkDelete) {
+	list, parseErr := jsh.ParseRelationshipList(r)
+	if parseErr != nil {
 		SendHandler(ctx, w, r, parseErr)
 		return
 	}
 
+	if len(list) == 0 {
+		SendHandler(ctx, w, r, jsh.BadRequestError("Invalid document", "Missing objects to delete"))
+		return
+	}
+
+	ids := make([]string, 0, len(list))
+	for _, object := range list {
+		ids = append(ids, object.ID)
+	}
+
+	start := time.Now()
+	err := storage(ctx, ids)
+	res.recordTiming(ctx, r, start)
+	if bulkErr, ok := err.(*store.BulkDeleteError); ok && bulkErr != nil {
+		doc := jsh.Build(bulkErr.Cause)
+		doc.Meta = map[string]interface{}{"failed_ids": bulkErr.FailedIDs}
+		SendHandler(ctx, w, r, doc)
+		return
+	}
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	res.addCacheBustHeaders(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /resources/:id responding with the deleted object
+func (res *Resource) deleteWithBodyHandler(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, storage store.CRUD) {
 	id := pat.Param(ctx, "id")
-	if id != parsedObject.ID {
-		SendHandler(ctx, w, r, jsh.ConflictError("", parsedObject.ID))
+
+	start := time.Now()
+	object, err := storage.Get(ctx, id)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
 		return
 	}
 
-	object, err := storage(ctx, parsedObject)
+	start = time.Now()
+	err = storage.Delete(ctx, id)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
 	}
 
+	res.emitEvent(ctx, r, store.EventDeleted, id, object)
+	res.addCacheBustHeaders(w)
+
+	// NOTE: Explicitly set status to 200 as no default status is set for DELETE
+	if object != nil {
+		object.Status = http.StatusOK
+	}
 	SendHandler(ctx, w, r, object)
 }
 
-// DELETE /resources/:id
-func (res *Resource) deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Delete) {
-	id := pat.Param(ctx, "id")
+// DELETE /resources/:id?reason=<reason>
+func (res *Resource) deleteWithReasonHandler(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, storage store.DeleteWithReason) {
+	reason := r.URL.Query().Get("reason")
+	if reason == "" && res.deleteReasonRequired {
+		SendHandler(ctx, w, r, jsh.ParameterError("A reason is required to delete the resource", "reason"))
+		return
+	}
 
-	err := storage(ctx, id)
+	id := pat.Param(ctx, "id")
+	start := time.Now()
+	err := storage(ctx, id, reason)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
 	}
 
+	res.addCacheBustHeaders(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // POST /resources/:id/<action>
 func (res *Resource) actionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Action) {
+	start := time.Now()
+	response, err := storage(ctx, w, r)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	// NOTE: Explicitly set status to 200 to avoid automatically setting it to 201 (default for POST)
+	if response != nil && response.Status == 0 {
+		response.Status = 200
+	}
+	SendHandler(ctx, w, r, response)
+}
+
+// POST /resources/:id/<action> responding with a list
+func (res *Resource) listActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.ListAction) {
+	start := time.Now()
+	list, err := storage(ctx, w, r)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	// NOTE: a list is always sent with a 200 status, an empty one instead of null data
+	if list == nil {
+		list = jsh.List{}
+	}
+	SendHandler(ctx, w, r, list)
+}
+
+// POST /resources/:id/<action> for a command
+func (res *Resource) commandHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Command) {
+	id := pat.Param(ctx, "id")
+
+	start := time.Now()
+	response, err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	// NOTE: Explicitly set status to 200 to avoid automatically setting it to 201 (default for POST)
+	if response != nil && response.Status == 0 {
+		response.Status = 200
+	}
+	SendHandler(ctx, w, r, response)
+}
+
+// GET /resources streamed a chunk at a time
+func (res *Resource) chunkedListHandler(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	storage store.ChunkedList, chunkSize int) {
+	start := time.Now()
+	defer res.recordTiming(ctx, r, start)
+
+	// fetch the first chunk before committing the response, so that its errors are sent as usual
+	list, more, err := storage.NextChunk(ctx, chunkSize)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.Header().Set("Content-Type", NDJSONContentType)
+
+	encoder := json.NewEncoder(w)
+	flusher, canFlush := w.(http.Flusher)
+	for {
+		if list == nil {
+			list = jsh.List{}
+		}
+		if encodeErr := encoder.Encode(jsh.Build(list)); encodeErr != nil {
+			Logger.Printf("Error writing chunked list: %s\n", encodeErr)
+			return
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+		if !more || ctx.Err() != nil {
+			return
+		}
+
+		list, more, err = storage.NextChunk(ctx, chunkSize)
+		if err != nil && reflect.ValueOf(err).IsNil() == false {
+			if encodeErr := encoder.Encode(jsh.Build(err)); encodeErr != nil {
+				Logger.Printf("Error writing chunked list error: %s\n", encodeErr)
+			}
+			return
+		}
+	}
+}
+
+// POST /resources/:id/<action> for a streaming action
+func (res *Resource) streamingActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	storage store.StreamingAction) {
+	id := pat.Param(ctx, "id")
+
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.Header().Set("Content-Type", NDJSONContentType)
+
+	start := time.Now()
+	err := storage(ctx, id, w, r)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		if encodeErr := json.NewEncoder(w).Encode(jsh.Build(err)); encodeErr != nil {
+			Logger.Printf("Error writing streaming action error: %s\n", encodeErr)
+		}
+	}
+}
+
+// POST /resources/:id/<action> with an Idempotency-Key header
+func (res *Resource) idempotentActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	action string, storage store.Action, cache store.IdempotencyCache) {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		res.actionHandler(ctx, w, r, storage)
+		return
+	}
+
+	// scope the key to the action so that keys of different endpoints never collide
+	key := fmt.Sprintf("%s/%s/%s:%s", res.Type, pat.Param(ctx, "id"), action, idempotencyKey)
+	if response, cached := cache.Get(key); cached {
+		SendHandler(ctx, w, r, response)
+		return
+	}
+
+	start := time.Now()
 	response, err := storage(ctx, w, r)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	// NOTE: Explicitly set status to 200 to avoid automatically setting it to 201 (default for POST)
+	if response != nil && response.Status == 0 {
+		response.Status = 200
+	}
+	if response != nil {
+		cache.Set(key, response, IdempotencyTTL)
+	}
+	SendHandler(ctx, w, r, response)
+}
+
+// POST /resources/:id/<action> with a relationship list body
+func (res *Resource) relationshipActionHandler(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, storage store.RelationshipAction) {
+	list, parseErr := jsh.ParseRelationshipList(r)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	id := pat.Param(ctx, "id")
+	start := time.Now()
+	response, err := storage(ctx, id, list, w, r)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
@@ -517,14 +2005,16 @@ func (res *Resource) actionHandler(ctx context.Context, w http.ResponseWriter, r
 // PATCH /resources/:id/relationships/<relationship> for a to-one relationship
 func (res *Resource) patchOneHandler(ctx context.Context, w http.ResponseWriter,
 	r *http.Request, storage store.ToOneUpdate) {
-	relationship, parseErr := jsh.ParseRelationship(r)
+	relationship, parseErr := parseToOneRelationship(r)
 	if parseErr != nil {
 		SendHandler(ctx, w, r, parseErr)
 		return
 	}
 
 	id := pat.Param(ctx, "id")
+	start := time.Now()
 	relationship, err := storage(ctx, id, relationship)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
@@ -533,12 +2023,35 @@ func (res *Resource) patchOneHandler(ctx context.Context, w http.ResponseWriter,
 	SendHandler(ctx, w, r, relationship)
 }
 
+// parseToOneRelationship parses a to-one relationship document, returning nil when the
+// relationship is set to null. Unlike jsh.ParseRelationship, a document without the
+// mandatory "data" member is rejected instead of being handled as a null relationship.
+func parseToOneRelationship(r *http.Request) (*jsh.IDObject, *jsh.Error) {
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, jsh.BadRequestError("Invalid JSON Document", err.Error())
+	}
+
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) == nil {
+		if _, hasData := members["data"]; !hasData {
+			return nil, jsh.BadRequestError("Invalid document", "Missing mandatory 'data' member")
+		}
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return jsh.ParseRelationship(r)
+}
+
 // GET /resources/:id/relationships/<relationship>
 func (res *Resource) fetchIDHandler(ctx context.Context, w http.ResponseWriter,
 	r *http.Request, storage store.ToOneGet) {
 	id := pat.Param(ctx, "id")
 
+	start := time.Now()
 	object, err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
@@ -552,7 +2065,30 @@ func (res *Resource) listManyHandler(ctx context.Context, w http.ResponseWriter,
 	r *http.Request, storage store.ToManyListResources) {
 	id := pat.Param(ctx, "id")
 
+	start := time.Now()
 	list, err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	SendHandler(ctx, w, r, list)
+}
+
+// GET /resources/:id/<relationship>/:<param>
+func (res *Resource) dynamicManyHandler(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, param string, storage store.DynamicToMany) {
+	id := pat.Param(ctx, "id")
+
+	var relParam string
+	if param != "" {
+		relParam = pat.Param(ctx, param)
+	}
+
+	start := time.Now()
+	list, err := storage(ctx, id, relParam)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
@@ -566,7 +2102,9 @@ func (res *Resource) listIDHandler(ctx context.Context, w http.ResponseWriter,
 	r *http.Request, storage store.ToManyList) {
 	id := pat.Param(ctx, "id")
 
+	start := time.Now()
 	list, err := storage(ctx, id)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
@@ -585,18 +2123,26 @@ func (res *Resource) patchManyHandler(ctx context.Context, w http.ResponseWriter
 	}
 
 	id := pat.Param(ctx, "id")
+	ctx = context.WithValue(ctx, store.ToManyOperationKey, store.ToManyReplace)
+	start := time.Now()
 	list, err := storage(ctx, id, list)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
 	}
 
+	// storage returns the full relationship list when it changed beyond the request
+	if list == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	SendHandler(ctx, w, r, list)
 }
 
 // POST/DELETE /resources/:id/relationships/<relationship> for a to-many relationship
 func (res *Resource) updateManyHandler(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, storage store.ToManyUpdate) {
+	r *http.Request, operation store.ToManyOperation, storage store.ToManyUpdate) {
 	list, parseErr := jsh.ParseRelationshipList(r)
 	if parseErr != nil {
 		SendHandler(ctx, w, r, parseErr)
@@ -609,7 +2155,10 @@ func (res *Resource) updateManyHandler(ctx context.Context, w http.ResponseWrite
 	}
 
 	id := pat.Param(ctx, "id")
+	ctx = context.WithValue(ctx, store.ToManyOperationKey, operation)
+	start := time.Now()
 	list, err := storage(ctx, id, list)
+	res.recordTiming(ctx, r, start)
 	if err != nil && reflect.ValueOf(err).IsNil() == false {
 		SendHandler(ctx, w, r, err)
 		return
@@ -618,6 +2167,76 @@ func (res *Resource) updateManyHandler(ctx context.Context, w http.ResponseWrite
 	SendHandler(ctx, w, r, list)
 }
 
+// POST /resources/:id/relationships/<relationship> for a to-many relationship storage implementing ToManyCreator
+func (res *Resource) createManyHandler(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, storage store.ToManyCreate) {
+	list, parseErr := jsh.ParseRelationshipList(r)
+	if parseErr != nil {
+		SendHandler(ctx, w, r, parseErr)
+		return
+	}
+
+	if len(list) == 0 {
+		SendHandler(ctx, w, r, jsh.BadRequestError("Invalid document", "Missing description of changes"))
+		return
+	}
+
+	id := pat.Param(ctx, "id")
+	ctx = context.WithValue(ctx, store.ToManyOperationKey, store.ToManyAdd)
+	start := time.Now()
+	list, created, err := storage(ctx, id, list)
+	res.recordTiming(ctx, r, start)
+	if err != nil && reflect.ValueOf(err).IsNil() == false {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if list == nil {
+		SendHandler(ctx, w, r, list)
+		return
+	}
+
+	doc := jsh.Build(list)
+	if created {
+		doc.Status = http.StatusCreated
+	}
+	SendHandler(ctx, w, r, doc)
+}
+
+// addCacheBustHeaders adds the cache invalidation headers to a mutation response if enabled.
+func (res *Resource) addCacheBustHeaders(w http.ResponseWriter) {
+	if !res.cacheBust {
+		return
+	}
+	w.Header().Set("Clear-Site-Data", `"cache"`)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// addLinks adds the self and relationship links to an object of the resource type
+// if links are enabled. Objects of another type, such as related resources, are left untouched.
+func (res *Resource) addLinks(object *jsh.Object) {
+	if !res.selfLinks || object == nil || object.Type != res.Type {
+		return
+	}
+
+	if object.Links == nil {
+		object.Links = map[string]*jsh.Link{}
+	}
+	object.AddSelfLink()
+
+	if object.Relationships == nil {
+		object.Relationships = map[string]*jsh.Relationship{}
+	}
+	for name := range res.Relationships {
+		// keep any resource linkage set by the storage
+		if relationship, exists := object.Relationships[name]; exists && relationship != nil {
+			relationship.Links = jsh.NewRelationshipLinks(object.ID, object.Type, name)
+			continue
+		}
+		object.AddRelationshipLinks(name)
+	}
+}
+
 // addRoute adds the new method and route to a route Tree for debugging and
 // informational purposes.
 func (res *Resource) addRoute(method string, route string, allow bool) {
@@ -628,12 +2247,39 @@ func (res *Resource) addRoute(method string, route string, allow bool) {
 	})
 }
 
+// addAction adds the route of a custom action to the route tree and records it as an action.
+func (res *Resource) addAction(action string, method string, route string, allow bool) {
+	res.addRoute(method, route, allow)
+	res.Actions[action] = res.Routes[len(res.Routes)-1]
+}
+
+// isAction returns true if the route is the route of a custom action.
+func (res *Resource) isAction(route Route) bool {
+	for _, action := range res.Actions {
+		if action == route {
+			return true
+		}
+	}
+	return false
+}
+
 // RouteTree prints a recursive route tree based on what the resource, and
-// all subresources have registered
+// all subresources have registered. Custom action routes are prefixed with [ACTION].
+// Paths are aligned on the action label and the longest method of the resource.
 func (res *Resource) RouteTree() string {
+	return res.routeTree(routeWidths(res))
+}
+
+// routeTree prints the route tree of the resource with label and method columns of the
+// given widths. Paths are the last column and are not padded.
+func (res *Resource) routeTree(labelWidth, methodWidth int) string {
 	var routes string
 	for _, route := range res.Routes {
-		routes = fmt.Sprintf("%s\n%s", routes, route)
+		label := ""
+		if res.isAction(route) {
+			label = actionLabel
+		}
+		routes = fmt.Sprintf("%s\n%-*s%s", routes, labelWidth, label, route.Format(methodWidth, 0))
 	}
 	return routes
 }
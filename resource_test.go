@@ -1,16 +1,25 @@
 package jshapi
 
 import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"goji.io/pat"
 
 	"github.com/EtixLabs/go-json-spec-handler"
 	"github.com/EtixLabs/go-json-spec-handler/client"
+	"github.com/EtixLabs/jsh-api/store"
 	. "github.com/smartystreets/goconvey/convey"
 	"golang.org/x/net/context"
 )
@@ -46,62 +55,2066 @@ func TestResource(t *testing.T) {
 			})
 		})
 
+		Convey("->NewCRUDResourceFromStruct()", func() {
+			type User struct{}
+			type Address struct{}
+
+			Convey("should derive the resource type from the struct name", func() {
+				So(NewCRUDResourceFromStruct(&MockStorage{}, User{}).Type, ShouldEqual, "users")
+				So(NewCRUDResourceFromStruct(&MockStorage{}, &Address{}).Type, ShouldEqual, "addresses")
+			})
+		})
+
+		Convey("->Options()", func() {
+			request, err := http.NewRequest("OPTIONS", baseURL+"/"+testResourceType+"/1", nil)
+			So(err, ShouldBeNil)
+			resp, err := http.DefaultClient.Do(request)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Allow"), ShouldEqual, "OPTIONS,HEAD,GET,PATCH,DELETE")
+			So(resp.Header.Get("Vary"), ShouldEqual, "Accept, Content-Type")
+			So(resp.Header.Get("Access-Control-Allow-Methods"), ShouldBeEmpty)
+		})
+
+		Convey("->EnableStructuredLogging()", func() {
+			logger := &mockStructuredLogger{}
+			resource.EnableStructuredLogging(logger)
+			defer resource.EnableStructuredLogging(nil)
+
+			_, _, err := jsc.Fetch(baseURL, testResourceType, "3")
+			So(err, ShouldBeNil)
+			_, resp, err := jsc.List(baseURL, testResourceType)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			So(logger.entries, ShouldResemble, []string{"bars GET 3 200", "bars GET  200"})
+		})
+
+		Convey("->SetCORS()", func() {
+			resource.SetCORS(true)
+			defer resource.SetCORS(false)
+
+			request, err := http.NewRequest("OPTIONS", baseURL+"/"+testResourceType+"/1", nil)
+			So(err, ShouldBeNil)
+			resp, err := http.DefaultClient.Do(request)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Access-Control-Allow-Methods"), ShouldEqual, "OPTIONS,HEAD,GET,PATCH,DELETE")
+		})
+
+		Convey("MockStorage.ListSort", func() {
+			fixedList := jsh.List{
+				sampleObject("2", testResourceType, testObjAttrs),
+				sampleObject("1", testResourceType, testObjAttrs),
+			}
+			storage := &MockStorage{FixedList: fixedList, ListSort: SortByID}
+
+			list, err := storage.List(context.Background())
+			So(err, ShouldBeNil)
+			So(list[0].ID, ShouldEqual, "1")
+			So(fixedList[0].ID, ShouldEqual, "2")
+		})
+
+		Convey("MockStorage.ComputedFields", func() {
+			storage := &MockStorage{
+				ResourceType:       testResourceType,
+				ResourceAttributes: testObjAttrs,
+				ComputedFields: map[string]func(id string) interface{}{
+					"full_name": func(id string) interface{} { return "User " + id },
+				},
+			}
+
+			attributes := map[string]interface{}{}
+			So(json.Unmarshal(storage.SampleObject("3").Attributes, &attributes), ShouldBeNil)
+			So(attributes, ShouldResemble, map[string]interface{}{"foo": "bar", "full_name": "User 3"})
+		})
+
 		Convey("->Post()", func() {
 			object := sampleObject("", testResourceType, testObjAttrs)
 			doc, resp, err := jsc.Post(baseURL, object)
 
-			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
-			So(err, ShouldBeNil)
-			So(doc.Data[0].ID, ShouldEqual, "1")
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			So(err, ShouldBeNil)
+			So(doc.Data[0].ID, ShouldEqual, "1")
+		})
+
+		Convey("->Post() should reject objects of another type", func() {
+			object := sampleObject("", "posts", testObjAttrs)
+			request, err := jsc.PostRequest(baseURL, object)
+			So(err, ShouldBeNil)
+			request.URL.Path = strings.Replace(request.URL.Path, "posts", testResourceType, 1)
+			_, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("->Post() should treat an empty ID as no ID", func() {
+			body := `{"data": {"type": "bars", "id": "", "attributes": {"foo": "bar"}}}`
+			resp, err := http.Post(baseURL+"/bars", jsh.ContentType, strings.NewReader(body))
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		})
+
+		Convey("->Post() should reject a blank ID", func() {
+			body := `{"data": {"type": "bars", "id": "  ", "attributes": {"foo": "bar"}}}`
+			resp, err := http.Post(baseURL+"/bars", jsh.ContentType, strings.NewReader(body))
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("->SetClientIDHeader()", func() {
+			resource.SetClientIDHeader("X-Trusted-Client")
+			defer resource.SetClientIDHeader("")
+			object := sampleObject("42", testResourceType, testObjAttrs)
+
+			Convey("should reject client generated IDs without the header", func() {
+				_, resp, err := jsc.Post(baseURL, object)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			})
+
+			Convey("should accept client generated IDs with the header", func() {
+				request, err := jsc.PostRequest(baseURL, object)
+				So(err, ShouldBeNil)
+				request.Header.Set("X-Trusted-Client", "true")
+				_, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			})
+		})
+
+		Convey("->List()", func() {
+			doc, resp, err := jsc.List(baseURL, testResourceType)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(err, ShouldBeNil)
+			So(len(doc.Data), ShouldEqual, 2)
+			So(doc.Data[0].ID, ShouldEqual, "1")
+		})
+
+		Convey("->Fetch()", func() {
+			doc, resp, err := jsc.Fetch(baseURL, testResourceType, "3")
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(err, ShouldBeNil)
+			So(doc.Data[0].ID, ShouldEqual, "3")
+		})
+
+		Convey("->Head()", func() {
+			resp, err := http.Head(baseURL + "/bars/1")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.ContentLength, ShouldBeGreaterThan, 0)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(body, ShouldBeEmpty)
+		})
+
+		Convey("->SetCRUDHooks()", func() {
+			defer resource.SetCRUDHooks(CRUDHooks{})
+
+			Convey("should pass the hook context to storage", func() {
+				var hooked interface{}
+				resource.SetCRUDHooks(CRUDHooks{PreList: []CRUDHook{
+					func(ctx context.Context, r *http.Request) (context.Context, error) {
+						return context.WithValue(ctx, TenantContextKey, "etix"), nil
+					},
+					func(ctx context.Context, r *http.Request) (context.Context, error) {
+						hooked = ctx.Value(TenantContextKey)
+						return ctx, nil
+					},
+				}})
+				_, resp, err := jsc.List(baseURL, testResourceType)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(hooked, ShouldEqual, "etix")
+			})
+
+			Convey("should respond with 403 for forbidden errors", func() {
+				resource.SetCRUDHooks(CRUDHooks{PreGet: []CRUDHook{
+					func(ctx context.Context, r *http.Request) (context.Context, error) {
+						return ctx, fmt.Errorf("read-only user: %w", ErrHookForbidden)
+					},
+				}})
+				_, resp, err := jsc.Fetch(baseURL, testResourceType, "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			})
+
+			Convey("should respond with 400 for other errors", func() {
+				resource.SetCRUDHooks(CRUDHooks{PreDelete: []CRUDHook{
+					func(ctx context.Context, r *http.Request) (context.Context, error) {
+						return ctx, errors.New("missing confirmation")
+					},
+				}})
+				resp, err := jsc.Delete(baseURL, testResourceType, "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("->EnableTiming()", func() {
+			resource.EnableTiming()
+			defer func() { resource.timings = nil }()
+
+			_, _, err := jsc.Fetch(baseURL, testResourceType, "3")
+			So(err, ShouldBeNil)
+
+			report := api.TimingReport()
+			So(report["GET /bars/:id"].Count, ShouldEqual, 1)
+			So(report["GET /bars/:id"].P95, ShouldBeGreaterThanOrEqualTo, report["GET /bars/:id"].Mean)
+
+			Convey("should record requests matching no route under a wildcard route", func() {
+				request := httptest.NewRequest("GET", "/bars/1/nope", nil)
+				resource.recordTiming(context.Background(), request, time.Now())
+
+				So(resource.TimingReport()["GET /bars/*"].Count, ShouldEqual, 1)
+			})
+		})
+
+		Convey("->Patch()", func() {
+
+			Convey("should reject requests with ID mismatch", func() {
+				object := sampleObject("1", testResourceType, testObjAttrs)
+				request, err := jsc.PatchRequest(baseURL, object)
+				So(err, ShouldBeNil)
+				// Manually replace resource ID in URL to be invalid
+				request.URL.Path = strings.Replace(request.URL.Path, "1", "2", 1)
+				doc, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(resp.StatusCode, ShouldEqual, 409)
+				So(err, ShouldBeNil)
+				So(doc, ShouldNotBeNil)
+			})
+
+			Convey("should reject requests with type mismatch", func() {
+				object := sampleObject("1", "posts", testObjAttrs)
+				request, err := jsc.PatchRequest(baseURL, object)
+				So(err, ShouldBeNil)
+				request.URL.Path = strings.Replace(request.URL.Path, "posts", testResourceType, 1)
+				_, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			})
+
+			Convey("should accept type mismatch without strict type checking", func() {
+				resource.SetStrictTypeChecking(false)
+				defer resource.SetStrictTypeChecking(true)
+				object := sampleObject("1", "posts", testObjAttrs)
+				request, err := jsc.PatchRequest(baseURL, object)
+				So(err, ShouldBeNil)
+				request.URL.Path = strings.Replace(request.URL.Path, "posts", testResourceType, 1)
+				_, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should accept patch requests", func() {
+				object := sampleObject("1", testResourceType, testObjAttrs)
+				doc, resp, err := jsc.Patch(baseURL, object)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(err, ShouldBeNil)
+				So(doc.Data[0].ID, ShouldEqual, "1")
+			})
+
+			Convey("->SetNoOpUpdateBehaviour()", func() {
+				defer resource.SetNoOpUpdateBehaviour(CallStorageAnyway)
+
+				Convey("should respond with 204 to no-op updates", func() {
+					resource.SetNoOpUpdateBehaviour(Return204)
+					_, resp, err := jsc.Patch(baseURL, sampleObject("1", testResourceType, testObjAttrs))
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				})
+
+				Convey("should respond with the current object to no-op updates", func() {
+					resource.SetNoOpUpdateBehaviour(Return200WithOld)
+					doc, resp, err := jsc.Patch(baseURL, sampleObject("1", testResourceType, testObjAttrs))
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+					So(doc.Data[0].ID, ShouldEqual, "1")
+				})
+
+				Convey("should call storage for updates changing the object", func() {
+					resource.SetNoOpUpdateBehaviour(Return204)
+					object := sampleObject("1", testResourceType, map[string]string{"foo": "baz"})
+					doc, resp, err := jsc.Patch(baseURL, object)
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+					So(string(doc.Data[0].Attributes), ShouldContainSubstring, "baz")
+				})
+			})
+		})
+
+		Convey("->ValidateIDMatch()", func() {
+			So(ValidateIDMatch("1", sampleObject("1", testResourceType, testObjAttrs)), ShouldBeNil)
+			So(ValidateIDMatch("1", sampleObject("2", testResourceType, testObjAttrs)).StatusCode(), ShouldEqual, http.StatusConflict)
+			So(ValidateIDMatch("1", nil).StatusCode(), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("->Delete()", func() {
+			resp, err := jsc.Delete(baseURL, testResourceType, "1")
+
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(err, ShouldBeNil)
+			So(resp.Header.Get("Clear-Site-Data"), ShouldBeEmpty)
+		})
+
+		Convey("->SetCacheBustOnMutation()", func() {
+			resource.SetCacheBustOnMutation(true)
+			defer resource.SetCacheBustOnMutation(false)
+
+			resp, err := jsc.Delete(baseURL, testResourceType, "1")
+
+			So(err, ShouldBeNil)
+			So(resp.Header.Get("Clear-Site-Data"), ShouldEqual, `"cache"`)
+			So(resp.Header.Get("Cache-Control"), ShouldEqual, "no-store")
+		})
+
+		Convey("->ValidateRouteOrder()", func() {
+
+			Convey("should not warn about CRUD routes", func() {
+				So(resource.ValidateRouteOrder(), ShouldBeEmpty)
+			})
+
+			Convey("should warn about a static route registered after a wildcard route", func() {
+				shadowResource := NewResource("shadows")
+				shadowResource.DynamicToMany("tags/:tagType", func(ctx context.Context, parentID, relParam string) (jsh.List, jsh.ErrorType) {
+					return nil, nil
+				}, true)
+				shadowResource.ListRelated(func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+					return nil, nil
+				}, "/:id/tags/system", true)
+
+				So(shadowResource.ValidateRouteOrder(), ShouldResemble, []string{
+					"HEAD /shadows/:id/tags/system is shadowed by HEAD /shadows/:id/tags/:tagType registered before it",
+					"GET /shadows/:id/tags/system is shadowed by GET /shadows/:id/tags/:tagType registered before it",
+				})
+			})
+
+			Convey("should not warn about static routes registered after the /:id routes", func() {
+				shadowResource := NewResource("shadows")
+				shadowResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+					return nil, nil
+				}, true)
+				shadowResource.Search(func(ctx context.Context, query string, filters store.FilterMap) (jsh.List, jsh.ErrorType) {
+					return nil, nil
+				}, true)
+
+				So(shadowResource.ValidateRouteOrder(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("->SetStrictReadOnly()", func() {
+			strictResource := NewResource("stricts")
+			strictResource.SetStrictReadOnly(true)
+
+			Convey("should panic when the body is written twice", func() {
+				w := strictResource.readOnly(httptest.NewRecorder())
+				w.Write([]byte("{}"))
+				So(func() { w.Write([]byte("{}")) }, ShouldPanic)
+			})
+
+			Convey("should panic when a success status is written after the body", func() {
+				w := strictResource.readOnly(httptest.NewRecorder())
+				w.Write([]byte("{}"))
+				So(func() { w.WriteHeader(http.StatusOK) }, ShouldPanic)
+			})
+
+			Convey("should allow a single response", func() {
+				w := strictResource.readOnly(httptest.NewRecorder())
+				So(func() {
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte("{}"))
+				}, ShouldNotPanic)
+			})
+		})
+	})
+}
+
+func TestAsyncPost(t *testing.T) {
+	asyncResource := NewResource("asyncs")
+	asyncResource.AsyncPost(func(ctx context.Context, object *jsh.Object) (*jsh.Object, string, jsh.ErrorType) {
+		object.ID = "1"
+		return object, "/jobs/1", nil
+	}, true)
+	nilAsyncResource := NewResource("nilasyncs")
+	nilAsyncResource.AsyncPost(func(ctx context.Context, object *jsh.Object) (*jsh.Object, string, jsh.ErrorType) {
+		return nil, "/jobs/2", nil
+	}, true)
+
+	api := New("")
+	api.Add(asyncResource)
+	api.Add(nilAsyncResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Async Post Tests", t, func() {
+
+		Convey("->AsyncPost()", func() {
+			object := sampleObject("", "asyncs", testObjAttrs)
+			doc, resp, err := jsc.Post(baseURL, object)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+			So(resp.Header.Get("Location"), ShouldEqual, "/jobs/1")
+			So(doc.Data[0].Meta["status"], ShouldEqual, "pending")
+
+			Convey("should respond 202 with the Location header only when storage returns no object", func() {
+				request, err := jsc.PostRequest(baseURL, sampleObject("", "nilasyncs", testObjAttrs))
+				So(err, ShouldBeNil)
+				resp, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				defer resp.Body.Close()
+
+				So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+				So(resp.Header.Get("Location"), ShouldEqual, "/jobs/2")
+				body, err := ioutil.ReadAll(resp.Body)
+				So(err, ShouldBeNil)
+				So(body, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestEmptyListBehaviour(t *testing.T) {
+	emptyResource := NewMockResource("empties", 0, testObjAttrs)
+
+	api := New("")
+	api.Add(emptyResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Empty List Behaviour Tests", t, func() {
+
+		Convey("->SetEmptyListBehaviour()", func() {
+			defer emptyResource.SetEmptyListBehaviour(Return200)
+
+			Convey("should respond with an empty list by default", func() {
+				doc, resp, err := jsc.List(baseURL, "empties")
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(err, ShouldBeNil)
+				So(doc.Data, ShouldBeEmpty)
+			})
+
+			Convey("should respond with a 404 when requested", func() {
+				emptyResource.SetEmptyListBehaviour(Return404)
+				_, resp, _ := jsc.List(baseURL, "empties")
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestListContext(t *testing.T) {
+	resource := NewMockResource(testResourceType, 2, testObjAttrs)
+
+	var listPermissions Permissions
+	var listHint store.ProjectionHint
+	var listFilters store.TypedFilterMap
+	var listMeta store.RequestMeta
+	var listCursor *store.SearchAfterCursor
+	var listQueryCtx context.Context
+	permissionsResource := NewResource("permissions")
+	permissionsResource.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+		listPermissions, _ = PermissionsFromContext(ctx)
+		listHint, _ = store.ProjectionHintFromContext(ctx)
+		listFilters, _ = store.TypedFiltersFromContext(ctx)
+		listMeta, _ = store.RequestMetaFromContext(ctx)
+		listCursor, _ = store.SearchAfterCursorFromContext(ctx)
+		listQueryCtx = ctx
+		return jsh.List{}, nil
+	}, true)
+	permissionsResource.SetPermissionsExtractor(func(r *http.Request) Permissions {
+		return Permissions{CanRead: r.Header.Get("X-Role") == "reader"}
+	})
+
+	api := New("")
+	api.Add(resource)
+	api.Add(permissionsResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("List Context Tests", t, func() {
+
+		Convey("->SetPermissionsExtractor()", func() {
+			request, err := jsc.ListRequest(baseURL, "permissions")
+			So(err, ShouldBeNil)
+			request.Header.Set("X-Role", "reader")
+			_, resp, err := jsc.Do(request, jsh.ListMode)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(listPermissions, ShouldResemble, Permissions{CanRead: true})
+		})
+
+		Convey("should pass a projection hint to storage", func() {
+			request, err := jsc.ListRequest(baseURL, "permissions")
+			So(err, ShouldBeNil)
+			request.URL.RawQuery = "fields[permissions]=name,email"
+			_, _, err = jsc.Do(request, jsh.ListMode)
+
+			So(err, ShouldBeNil)
+			So(listHint, ShouldResemble, store.ProjectionHint{"name", "email"})
+		})
+
+		Convey("should pass the request meta to storage", func() {
+
+			Convey("should default to an empty map", func() {
+				_, _, err := jsc.List(baseURL, "permissions")
+
+				So(err, ShouldBeNil)
+				So(listMeta, ShouldResemble, store.RequestMeta{})
+			})
+
+			Convey("should decode the header", func() {
+				request, err := jsc.ListRequest(baseURL, "permissions")
+				So(err, ShouldBeNil)
+				request.Header.Set("X-Request-Meta", `{"consistency":"strong","bypassCache":true}`)
+				_, _, err = jsc.Do(request, jsh.ListMode)
+
+				So(err, ShouldBeNil)
+				So(listMeta, ShouldResemble, store.RequestMeta{"consistency": "strong", "bypassCache": true})
+			})
+
+			Convey("should reject a nested or malformed header", func() {
+				for _, header := range []string{`{"replica":{"zone":"a"}}`, `{`} {
+					request, err := jsc.ListRequest(baseURL, "permissions")
+					So(err, ShouldBeNil)
+					request.Header.Set("X-Request-Meta", header)
+					_, resp, _ := jsc.Do(request, jsh.ListMode)
+
+					So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+				}
+			})
+		})
+
+		Convey("->SetParameterWarnings()", func() {
+			permissionsResource.SetParameterWarnings(true)
+			defer permissionsResource.SetParameterWarnings(false)
+
+			request, err := jsc.ListRequest(baseURL, "permissions")
+			So(err, ShouldBeNil)
+			request.URL.RawQuery = "unknown_param=value&sort=name&page[size]=10&other=1"
+			_, resp, err := jsc.Do(request, jsh.ListMode)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header["Warning"], ShouldResemble, []string{
+				`199 - "Unrecognised parameter: other"`,
+				`199 - "Unrecognised parameter: unknown_param"`,
+			})
+		})
+
+		Convey("->SetFilterSchema()", func() {
+			permissionsResource.SetFilterSchema(map[string]string{"age": "int", "admin": "bool"})
+			defer permissionsResource.SetFilterSchema(nil)
+
+			Convey("should pass typed filters to storage", func() {
+				request, err := jsc.ListRequest(baseURL, "permissions")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "filter[age]=18,21&filter[admin]=true&filter[name]=bob"
+				_, resp, err := jsc.Do(request, jsh.ListMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(listFilters, ShouldResemble, store.TypedFilterMap{
+					"age":   []store.FilterValue{18, 21},
+					"admin": true,
+				})
+			})
+
+			Convey("should reject a value of an invalid type", func() {
+				request, err := jsc.ListRequest(baseURL, "permissions")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "filter[age]=old"
+				_, resp, _ := jsc.Do(request, jsh.ListMode)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("->List() should store the query in the context", func() {
+			request, err := jsc.ListRequest(baseURL, "permissions")
+			So(err, ShouldBeNil)
+			request.URL.RawQuery = "page[size]=10&filter[name]=bob&sort=-age,name&fields[permissions]=name"
+			_, resp, err := jsc.Do(request, jsh.ListMode)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			page, _ := PaginationFromContext(listQueryCtx)
+			So(page, ShouldResemble, PageParams{"size": "10"})
+			filters, _ := FilterFromContext(listQueryCtx)
+			So(filters, ShouldResemble, store.FilterMap{"name": {"bob"}})
+			sort, _ := SortFromContext(listQueryCtx)
+			So(sort, ShouldResemble, []string{"-age", "name"})
+			fields, _ := FieldSetFromContext(listQueryCtx)
+			So(fields, ShouldResemble, []string{"name"})
+			_, hasTenant := TenantFromContext(listQueryCtx)
+			So(hasTenant, ShouldBeFalse)
+		})
+
+		Convey("->List() with a search-after cursor", func() {
+			Convey("should pass the decoded cursor to storage", func() {
+				cursor := &store.SearchAfterCursor{LastID: "7", LastField: "bob"}
+				request, err := jsc.ListRequest(baseURL, "permissions")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "page[after]=" + encodeSearchAfter(cursor)
+				_, resp, err := jsc.Do(request, jsh.ListMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(listCursor, ShouldResemble, cursor)
+			})
+
+			Convey("should reject an invalid cursor", func() {
+				request, err := jsc.ListRequest(baseURL, "permissions")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "page[after]=invalid"
+				_, resp, _ := jsc.Do(request, jsh.ListMode)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("should link to the next page", func() {
+				request, err := jsc.ListRequest(baseURL, testResourceType)
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "page[after]=&sort=foo"
+				resp, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				defer resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+				var doc struct {
+					Data  []json.RawMessage `json:"data"`
+					Links map[string]string `json:"links"`
+				}
+				So(json.NewDecoder(resp.Body).Decode(&doc), ShouldBeNil)
+				So(doc.Data, ShouldNotBeEmpty)
+				next := url.Values{
+					"page[after]": {encodeSearchAfter(&store.SearchAfterCursor{LastID: "2", LastField: "bar"})},
+					"sort":        {"foo"},
+				}
+				So(doc.Links["next"], ShouldEqual, fmt.Sprintf("/%s?%s", testResourceType, next.Encode()))
+				So(resp.Header.Get("Link"), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestCursorList(t *testing.T) {
+	type testCursor struct{ ID string }
+	var listedAfter *testCursor
+	cursorResource := NewResource("cursors")
+	CursorList(cursorResource, func(ctx context.Context, after, before *testCursor) (jsh.List, store.CursorPage[testCursor], jsh.ErrorType) {
+		listedAfter = after
+		page := store.CursorPage[testCursor]{Next: &testCursor{ID: "2"}}
+		if after != nil {
+			page.Prev = &testCursor{ID: "2"}
+		}
+		return jsh.List{sampleObject("2", "cursors", testObjAttrs)}, page, nil
+	}, store.Base64JSONEncoder[testCursor]{}, true)
+
+	api := New("")
+	api.Add(cursorResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Cursor List Tests", t, func() {
+
+		Convey("CursorList()", func() {
+			encoder := store.Base64JSONEncoder[testCursor]{}
+
+			Convey("should decode the cursor and link to the surrounding pages", func() {
+				after, err := encoder.Encode(testCursor{ID: "1"})
+				So(err, ShouldBeNil)
+				resp, err := http.Get(baseURL + "/cursors?page[after]=" + after)
+				So(err, ShouldBeNil)
+				defer resp.Body.Close()
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(resp.Header.Get("Link"), ShouldBeEmpty)
+				So(listedAfter, ShouldResemble, &testCursor{ID: "1"})
+
+				var doc struct {
+					Data  []json.RawMessage `json:"data"`
+					Links map[string]string `json:"links"`
+				}
+				So(json.NewDecoder(resp.Body).Decode(&doc), ShouldBeNil)
+				So(len(doc.Data), ShouldEqual, 1)
+
+				cursor, err := encoder.Encode(testCursor{ID: "2"})
+				So(err, ShouldBeNil)
+				So(doc.Links, ShouldResemble, map[string]string{
+					"next": "/cursors?" + url.Values{"page[after]": {cursor}}.Encode(),
+					"prev": "/cursors?" + url.Values{"page[before]": {cursor}}.Encode(),
+				})
+			})
+
+			Convey("should reject an invalid cursor", func() {
+				request, err := jsc.ListRequest(baseURL, "cursors")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "page[after]=invalid"
+				_, resp, _ := jsc.Do(request, jsh.ListMode)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestPanicFormatter(t *testing.T) {
+	panicResource := NewResource("panics")
+	panicResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		panic("storage failure")
+	}, true)
+	panicResource.SetPanicFormatter(func(recovered interface{}, stack []byte) jsh.ErrorType {
+		return &jsh.Error{Title: fmt.Sprint(recovered), Status: http.StatusServiceUnavailable}
+	})
+
+	api := New("")
+	api.Add(panicResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Panic Formatter Tests", t, func() {
+
+		Convey("->SetPanicFormatter()", func() {
+			doc, resp, err := jsc.Fetch(baseURL, "panics", "1")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(doc.Errors[0].Title, ShouldEqual, "storage failure")
+
+			Convey("should expose the panic value in meta in debug mode only", func() {
+				for _, debug := range []bool{false, true} {
+					resource := NewResource("defaultpanics")
+					resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+						panic("storage failure")
+					}, true)
+					var opts []APIOption
+					if debug {
+						opts = append(opts, WithDebug())
+					}
+					api := New("", opts...)
+					api.Add(resource)
+
+					server := httptest.NewServer(api)
+					doc, resp, err := jsc.Fetch(server.URL, "defaultpanics", "1")
+					server.Close()
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+					So(doc.Errors[0].Detail, ShouldEqual, jsh.DefaultErrorDetail)
+					if debug {
+						So(doc.Meta, ShouldResemble, map[string]interface{}{"panic": "storage failure"})
+					} else {
+						So(doc.Meta, ShouldBeNil)
+					}
+				}
+			})
+		})
+	})
+}
+
+func TestPatchStorage(t *testing.T) {
+	unchangedResource := NewResource("unchanged")
+	unchangedResource.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		return nil, nil
+	}, true)
+
+	diffUpdater := &mockDiffUpdater{MockStorage: MockStorage{ResourceType: "diffs", ResourceAttributes: testObjAttrs}}
+	diffResource := NewCRUDResource("diffs", diffUpdater)
+
+	trackingResource := NewCRUDResource("trackings", &mockTrackingUpdater{
+		MockStorage: MockStorage{ResourceType: "trackings", ResourceAttributes: testObjAttrs},
+	})
+
+	api := New("")
+	api.Add(unchangedResource)
+	api.Add(diffResource)
+	api.Add(trackingResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Patch Storage Tests", t, func() {
+
+		Convey("->Patch()", func() {
+
+			Convey("should respond with 204 when storage returns no object", func() {
+				object := sampleObject("1", "unchanged", testObjAttrs)
+				doc, resp, err := jsc.Patch(baseURL, object)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				So(err, ShouldBeNil)
+				So(doc, ShouldBeNil)
+			})
+
+			Convey("should pass the current object to a DiffUpdater storage", func() {
+				object := sampleObject("1", "diffs", map[string]string{"foo": "baz"})
+				doc, resp, err := jsc.Patch(baseURL, object)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(err, ShouldBeNil)
+				So(doc.Data[0].ID, ShouldEqual, "1")
+				So(diffUpdater.old, ShouldNotBeNil)
+				So(diffUpdater.old.ID, ShouldEqual, "1")
+				So(string(diffUpdater.old.Attributes), ShouldNotEqual, string(doc.Data[0].Attributes))
+			})
+		})
+
+		Convey("->PatchTracking()", func() {
+			object := sampleObject("1", "trackings", testObjAttrs)
+			doc, resp, err := jsc.Patch(baseURL, object)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Data[0].Meta["changed_fields"], ShouldResemble, []interface{}{"foo"})
+		})
+	})
+}
+
+func TestIDParsing(t *testing.T) {
+	var fetchedComponents store.IDComponents
+	compositeResource := NewResource("composites")
+	compositeResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		fetchedComponents, _ = store.IDComponentsFromContext(ctx)
+		return sampleObject(id, "composites", testObjAttrs), nil
+	}, true)
+	compositeResource.SetIDParser(CompositeIDParser(":", "orgId", "resourceId"))
+
+	slugResource := NewResource("slugs")
+	slugResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return sampleObject(id, "slugs", testObjAttrs), nil
+	}, true)
+	slugResource.SetIDTransformer(func(ctx context.Context, rawID string) (string, jsh.ErrorType) {
+		if rawID != "my-article" {
+			return "", jsh.NotFound("slugs", rawID)
+		}
+		return "42", nil
+	})
+
+	api := New("")
+	api.Add(compositeResource)
+	api.Add(slugResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("ID Parsing Tests", t, func() {
+
+		Convey("->SetIDParser()", func() {
+
+			Convey("should pass the ID components to storage", func() {
+				_, resp, err := jsc.Fetch(baseURL, "composites", "etix:42")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(fetchedComponents, ShouldResemble, store.IDComponents{"orgId": "etix", "resourceId": "42"})
+			})
+
+			Convey("should reject an invalid ID", func() {
+				_, resp, _ := jsc.Fetch(baseURL, "composites", "42")
+
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("->SetIDTransformer()", func() {
+
+			Convey("should pass the transformed ID to storage", func() {
+				doc, resp, err := jsc.Fetch(baseURL, "slugs", "my-article")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].ID, ShouldEqual, "42")
+			})
+
+			Convey("should send the transformer error", func() {
+				_, resp, _ := jsc.Fetch(baseURL, "slugs", "unknown")
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestIdempotentDelete(t *testing.T) {
+	idempotentResource := NewResource("idempotents")
+	idempotentResource.IdempotentDelete(func(ctx context.Context, id string) (bool, jsh.ErrorType) {
+		return id == "1", nil
+	}, true)
+
+	api := New("")
+	api.Add(idempotentResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Idempotent Delete Tests", t, func() {
+
+		Convey("->IdempotentDelete()", func() {
+
+			Convey("should respond with 204 if the object existed", func() {
+				resp, err := jsc.Delete(baseURL, "idempotents", "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			})
+
+			Convey("should respond with 404 if the object did not exist", func() {
+				resp, err := jsc.Delete(baseURL, "idempotents", "2")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestBulkDelete(t *testing.T) {
+	var bulkDeleted []string
+	bulkResource := NewResource("bulks")
+	bulkResource.BulkDelete(func(ctx context.Context, ids []string) jsh.ErrorType {
+		bulkDeleted = ids
+		if ids[len(ids)-1] == "404" {
+			return &store.BulkDeleteError{Cause: jsh.NotFound("bulks", "404"), FailedIDs: []string{"404"}}
+		}
+		return nil
+	}, true)
+
+	api := New("")
+	api.Add(bulkResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Bulk Delete Tests", t, func() {
+
+		Convey("->BulkDelete()", func() {
+			bulkDelete := func(body string) *http.Response {
+				request, err := http.NewRequest("DELETE", baseURL+"/bulks", strings.NewReader(body))
+				So(err, ShouldBeNil)
+				request.Header.Set("Content-Type", jsh.ContentType)
+				resp, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				return resp
+			}
+
+			Convey("should delete all objects", func() {
+				resp := bulkDelete(`{"data":[{"type":"bulks","id":"1"},{"type":"bulks","id":"2"}]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				So(bulkDeleted, ShouldResemble, []string{"1", "2"})
+			})
+
+			Convey("should report failed IDs", func() {
+				resp := bulkDelete(`{"data":[{"type":"bulks","id":"1"},{"type":"bulks","id":"404"}]}`)
+				var body struct {
+					Meta map[string][]string
+				}
+				So(json.NewDecoder(resp.Body).Decode(&body), ShouldBeNil)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+				So(body.Meta["failed_ids"], ShouldResemble, []string{"404"})
+			})
+
+			Convey("should reject an empty list", func() {
+				resp := bulkDelete(`{"data":[]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestDeleteRequiresGet(t *testing.T) {
+	fixedResource := NewCRUDResource("fixeds", &MockStorage{
+		ResourceType: "fixeds",
+		FixedList:    jsh.List{sampleObject("1", "fixeds", testObjAttrs)},
+	})
+
+	api := New("")
+	api.Add(fixedResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Delete Requires Get Tests", t, func() {
+
+		Convey("->SetDeleteRequiresGet()", func() {
+			fixedResource.SetDeleteRequiresGet(true)
+			defer fixedResource.SetDeleteRequiresGet(false)
+
+			Convey("should delete an existing object", func() {
+				resp, err := jsc.Delete(baseURL, "fixeds", "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			})
+
+			Convey("should send the error of Get without deleting", func() {
+				resp, err := jsc.Delete(baseURL, "fixeds", "2")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var breakerCalls int
+	breakerResource := NewResource("breakers")
+	breakerResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		breakerCalls++
+		return nil, jsh.ISE("storage failure")
+	}, true)
+
+	api := New("")
+	api.Add(breakerResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Circuit Breaker Tests", t, func() {
+
+		Convey("->SetCircuitBreaker()", func() {
+			breaker := NewSimpleBreaker(SimpleBreakerConfig{Threshold: 2, Timeout: time.Minute})
+			breakerResource.SetCircuitBreaker(breaker)
+			breakerCalls = 0
+
+			for i := 0; i < 2; i++ {
+				_, resp, _ := jsc.Fetch(baseURL, "breakers", "1")
+				So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			}
+			So(breaker.State(), ShouldEqual, CircuitOpen)
+
+			_, resp, _ := jsc.Fetch(baseURL, "breakers", "1")
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(resp.Header.Get("Retry-After"), ShouldEqual, "60")
+			So(breakerCalls, ShouldEqual, 2)
+
+			Convey("should round sub-second timeouts up in Retry-After", func() {
+				breaker.Timeout = 500 * time.Millisecond
+
+				_, resp, _ := jsc.Fetch(baseURL, "breakers", "1")
+				So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+				So(resp.Header.Get("Retry-After"), ShouldEqual, "1")
+			})
+
+			Convey("should let a single probe through once half-open", func() {
+				breaker.Timeout = 0
+				probing := make(chan struct{})
+				release := make(chan struct{})
+				done := make(chan error)
+				go func() {
+					done <- breaker.Call(func() error {
+						close(probing)
+						<-release
+						return nil
+					})
+				}()
+				<-probing
+
+				So(breaker.State(), ShouldEqual, CircuitHalfOpen)
+				So(breaker.Call(func() error { return nil }), ShouldEqual, ErrCircuitOpen)
+
+				close(release)
+				So(<-done, ShouldBeNil)
+				So(breaker.State(), ShouldEqual, CircuitClosed)
+			})
+		})
+	})
+}
+
+func TestDeleteWithBody(t *testing.T) {
+	bodyResource := NewResource("bodies")
+	bodyResource.DeleteWithBody(&MockStorage{ResourceType: "bodies", ResourceAttributes: testObjAttrs}, true)
+
+	api := New("")
+	api.Add(bodyResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Delete With Body Tests", t, func() {
+
+		Convey("->DeleteWithBody()", func() {
+			request, err := jsc.DeleteRequest(baseURL, "bodies", "1")
+			So(err, ShouldBeNil)
+			doc, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Data[0].ID, ShouldEqual, "1")
+		})
+	})
+}
+
+func TestPagedList(t *testing.T) {
+	var pagination store.Pagination
+	pagedResource := NewResource("pages")
+	pagedResource.PagedList(func(ctx context.Context, p store.Pagination) (jsh.List, jsh.ErrorType) {
+		pagination = p
+		return jsh.List{sampleObject("1", "pages", testObjAttrs)}, nil
+	}, true)
+
+	countedResource := NewResource("counteds")
+	countedResource.CountedPagedList(func(ctx context.Context, p store.Pagination) (store.PageResult, jsh.ErrorType) {
+		return store.PageResult{List: jsh.List{sampleObject("1", "counteds", testObjAttrs)}, Total: 25}, nil
+	}, true)
+
+	api := New("")
+	api.Add(pagedResource)
+	api.Add(countedResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	// pageLinks gets the paged list and returns the top-level links of the response document
+	pageLinks := func(url string) map[string]string {
+		resp, err := http.Get(url)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(resp.Header.Get("Link"), ShouldBeEmpty)
+
+		var doc struct {
+			Data  []json.RawMessage `json:"data"`
+			Links map[string]string `json:"links"`
+		}
+		So(json.NewDecoder(resp.Body).Decode(&doc), ShouldBeNil)
+		So(doc.Data, ShouldHaveLength, 1)
+		return doc.Links
+	}
+
+	Convey("Paged List Tests", t, func() {
+
+		Convey("->PagedList()", func() {
+
+			Convey("should pass the page parameters to storage", func() {
+				resp, err := http.Get(baseURL + "/pages?page[number]=2&page[size]=10&page[offset]=5&page[limit]=3")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(pagination, ShouldResemble, store.Pagination{Number: 2, Size: 10, Offset: 5, Limit: 3})
+			})
+
+			Convey("should not link the surrounding pages without a total", func() {
+				So(pageLinks(baseURL+"/pages?page[number]=2&page[size]=10"), ShouldBeEmpty)
+			})
+
+			Convey("should reject invalid page parameters", func() {
+				resp, err := http.Get(baseURL + "/pages?page[size]=0")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("->CountedPagedList()", func() {
+
+			Convey("should link the surrounding pages by number", func() {
+				So(pageLinks(baseURL+"/counteds?page[number]=2&page[size]=10"), ShouldResemble, map[string]string{
+					"first": "/counteds?page%5Bnumber%5D=1&page%5Bsize%5D=10",
+					"prev":  "/counteds?page%5Bnumber%5D=1&page%5Bsize%5D=10",
+					"next":  "/counteds?page%5Bnumber%5D=3&page%5Bsize%5D=10",
+					"last":  "/counteds?page%5Bnumber%5D=3&page%5Bsize%5D=10",
+				})
+			})
+
+			Convey("should link the surrounding pages by offset", func() {
+				So(pageLinks(baseURL+"/counteds?page[offset]=0&page[limit]=10"), ShouldResemble, map[string]string{
+					"first": "/counteds?page%5Blimit%5D=10&page%5Boffset%5D=0",
+					"next":  "/counteds?page%5Blimit%5D=10&page%5Boffset%5D=10",
+					"last":  "/counteds?page%5Blimit%5D=10&page%5Boffset%5D=20",
+				})
+			})
+		})
+	})
+}
+
+func TestBeforeHooks(t *testing.T) {
+	var beforeCalls []string
+	beforeResource := NewMockResource("befores", 1, testObjAttrs)
+	beforeResource.BeforeCreate(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		beforeCalls = append(beforeCalls, "stamp")
+		object.Attributes = json.RawMessage(`{"foo":"bar","created_by":"alice"}`)
+		return object, nil
+	})
+	beforeResource.BeforeCreate(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		beforeCalls = append(beforeCalls, "validate")
+		return nil, nil
+	})
+	beforeResource.BeforeFetch(func(ctx context.Context, id string) jsh.ErrorType {
+		if id == "2" {
+			return jsh.ForbiddenError("Object 2 is private")
+		}
+		return nil
+	})
+	beforeResource.BeforeDelete(func(ctx context.Context, id string) jsh.ErrorType {
+		beforeCalls = append(beforeCalls, "first")
+		return jsh.ForbiddenError("Objects cannot be deleted")
+	})
+	beforeResource.BeforeDelete(func(ctx context.Context, id string) jsh.ErrorType {
+		beforeCalls = append(beforeCalls, "second")
+		return nil
+	})
+
+	api := New("")
+	api.Add(beforeResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Before Hooks Tests", t, func() {
+
+		Convey("->BeforeCreate()", func() {
+			beforeCalls = nil
+			doc, resp, err := jsc.Post(baseURL, sampleObject("", "befores", testObjAttrs))
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			So(string(doc.Data[0].Attributes), ShouldContainSubstring, `"alice"`)
+			So(beforeCalls, ShouldResemble, []string{"stamp", "validate"})
+		})
+
+		Convey("->BeforeFetch()", func() {
+			_, resp, err := jsc.Fetch(baseURL, "befores", "1")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			_, resp, err = jsc.Fetch(baseURL, "befores", "2")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("->BeforeDelete() should stop at the first error", func() {
+			beforeCalls = nil
+			resp, err := jsc.Delete(baseURL, "befores", "1")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(beforeCalls, ShouldResemble, []string{"first"})
+		})
+	})
+}
+
+func TestFilteredList(t *testing.T) {
+	var queryParams store.QueryParams
+	filteredResource := NewResource("filtereds")
+	filteredResource.FilteredList(func(ctx context.Context, params store.QueryParams) (jsh.List, jsh.ErrorType) {
+		queryParams = params
+		return jsh.List{}, nil
+	}, true)
+
+	api := New("")
+	api.Add(filteredResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Filtered List Tests", t, func() {
+
+		Convey("->FilteredList()", func() {
+			queryParams = store.QueryParams{}
+
+			Convey("should pass the filters and sort fields to storage", func() {
+				resp, err := http.Get(baseURL + "/filtereds?filter[name]=foo&filter[age]=42&sort=name,-created_at")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(queryParams, ShouldResemble, store.QueryParams{
+					Filters: map[string]string{"name": "foo", "age": "42"},
+					Sort:    []store.SortField{{Field: "name"}, {Field: "created_at", Descending: true}},
+				})
+			})
+
+			Convey("should reject malformed sort fields", func() {
+				for _, sort := range []string{"name,,age", "--name", "-", ""} {
+					resp, err := http.Get(baseURL + "/filtereds?sort=" + sort)
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+				}
+				So(queryParams.Filters, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestStatefulMockStorage(t *testing.T) {
+	statefulResource := NewCRUDResource("states", NewStatefulMockStorage("states"))
+
+	api := New("")
+	api.Add(statefulResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Stateful Mock Storage Tests", t, func() {
+
+		Convey("->NewStatefulMockStorage()", func() {
+			doc, resp, err := jsc.Post(baseURL, sampleObject("", "states", testObjAttrs))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			id := doc.Data[0].ID
+
+			doc, resp, err = jsc.Patch(baseURL, sampleObject(id, "states", map[string]string{"foo": "baz"}))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			doc, resp, err = jsc.List(baseURL, "states")
+			So(err, ShouldBeNil)
+			So(len(doc.Data), ShouldEqual, 1)
+			So(string(doc.Data[0].Attributes), ShouldContainSubstring, "baz")
+
+			resp, err = jsc.Delete(baseURL, "states", id)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+
+			_, resp, err = jsc.Fetch(baseURL, "states", id)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->clientClosed()", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			body := `{"data":{"type":"states","attributes":{"foo":"bar"}}}`
+			request, _ := http.NewRequest("POST", "/states", strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			w := httptest.NewRecorder()
+			api.ServeHTTPC(ctx, w, request)
+			So(w.Code, ShouldEqual, StatusClientClosedRequest)
+
+			request, _ = http.NewRequest("GET", "/states", nil)
+			w = httptest.NewRecorder()
+			api.ServeHTTPC(ctx, w, request)
+			So(w.Code, ShouldEqual, StatusClientClosedRequest)
+
+			doc, resp, err := jsc.List(baseURL, "states")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Data, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestChunkedList(t *testing.T) {
+	chunkedResource := NewResource("chunkeds")
+	chunkedResource.ChunkedList(&mockChunkedList{objects: jsh.List{
+		sampleObject("1", "chunkeds", testObjAttrs),
+		sampleObject("2", "chunkeds", testObjAttrs),
+		sampleObject("3", "chunkeds", testObjAttrs),
+	}}, 2, true)
+
+	api := New("")
+	api.Add(chunkedResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Chunked List Tests", t, func() {
+
+		Convey("->ChunkedList()", func() {
+			response, err := http.Get(baseURL + "/chunkeds")
+			So(err, ShouldBeNil)
+			defer response.Body.Close()
+
+			So(response.StatusCode, ShouldEqual, http.StatusOK)
+			So(response.Header.Get("Content-Type"), ShouldEqual, NDJSONContentType)
+			So(response.TransferEncoding, ShouldResemble, []string{"chunked"})
+
+			var ids []string
+			decoder := json.NewDecoder(response.Body)
+			for decoder.More() {
+				var chunk struct {
+					Data []struct {
+						ID string `json:"id"`
+					} `json:"data"`
+				}
+				So(decoder.Decode(&chunk), ShouldBeNil)
+				for _, object := range chunk.Data {
+					ids = append(ids, object.ID)
+				}
+			}
+			So(ids, ShouldResemble, []string{"1", "2", "3"})
+
+			Convey("should send a JSON:API error if the first chunk fails", func() {
+				failing := NewResource("failings")
+				failing.ChunkedList(&mockChunkedList{err: jsh.ISE("listing failed")}, 2, true)
+				failingAPI := New("")
+				failingAPI.Add(failing)
+				failingServer := httptest.NewServer(failingAPI)
+				defer failingServer.Close()
+
+				response, err := http.Get(failingServer.URL + "/failings")
+				So(err, ShouldBeNil)
+				defer response.Body.Close()
+
+				So(response.StatusCode, ShouldEqual, http.StatusInternalServerError)
+				So(response.Header.Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			})
+		})
+	})
+}
+
+func TestErrorMapper(t *testing.T) {
+	sqlResource := NewResource("sqls")
+	sqlResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return nil, &mockSQLError{cause: fmt.Errorf("user %s: %w", id, sql.ErrNoRows)}
+	}, true)
+	sqlResource.SetErrorMapper(store.DefaultSQLErrorMapper)
+
+	api := New("")
+	api.Add(sqlResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Error Mapper Tests", t, func() {
+
+		Convey("->SetErrorMapper()", func() {
+			_, resp, err := jsc.Fetch(baseURL, "sqls", "1")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+func TestLinkHandler(t *testing.T) {
+	var linked jsh.IDList
+	linkResource := NewResource("links")
+	linkResource.LinkHandler(func(ctx context.Context, id string, targets jsh.IDList) jsh.ErrorType {
+		linked = targets
+		return nil
+	}, true)
+	linkResource.UnlinkHandler(nil, false)
+
+	api := New("")
+	api.Add(linkResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Link Handler Tests", t, func() {
+
+		Convey("->LinkHandler()", func() {
+			body := `{"data": [{"type": "tags", "id": "1"}, {"type": "tags", "id": "2"}]}`
+			request, err := http.NewRequest("LINK", baseURL+"/links/1", strings.NewReader(body))
+			So(err, ShouldBeNil)
+			request.Header.Set("Content-Type", jsh.ContentType)
+			resp, err := http.DefaultClient.Do(request)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(len(linked), ShouldEqual, 2)
+			So(linked[1].ID, ShouldEqual, "2")
+		})
+
+		Convey("->UnlinkHandler() should list LINK in the Allow header when disallowed", func() {
+			request, err := http.NewRequest("UNLINK", baseURL+"/links/1", nil)
+			So(err, ShouldBeNil)
+			resp, err := http.DefaultClient.Do(request)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+			So(resp.Header.Get("Allow"), ShouldEqual, "LINK")
+		})
+	})
+}
+
+func TestDeleteWithReason(t *testing.T) {
+	var deleteReason string
+	reasonResource := NewResource("reasons")
+	reasonResource.DeleteWithReason(func(ctx context.Context, id, reason string) jsh.ErrorType {
+		deleteReason = reason
+		return nil
+	}, true)
+	reasonResource.SetDeleteReasonRequired(true)
+
+	api := New("")
+	api.Add(reasonResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Delete With Reason Tests", t, func() {
+
+		Convey("->DeleteWithReason()", func() {
+
+			Convey("should pass the reason to storage", func() {
+				request, err := jsc.DeleteRequest(baseURL, "reasons", "1")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "reason=duplicate"
+				_, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				So(deleteReason, ShouldEqual, "duplicate")
+			})
+
+			Convey("should reject a missing reason when required", func() {
+				resp, err := jsc.Delete(baseURL, "reasons", "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestArchive(t *testing.T) {
+	archiver := &mockArchiver{MockStorage: MockStorage{ResourceType: "archives", ResourceAttributes: testObjAttrs}}
+	archiveResource := NewCRUDResource("archives", archiver)
+	archiveResource.WithArchive(archiver)
+
+	api := New("")
+	api.Add(archiveResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Archive Tests", t, func() {
+
+		Convey("->WithArchive()", func() {
+			archiver.archived = map[string]bool{}
+
+			Convey("should archive and unarchive objects", func() {
+				resp, err := jsc.Delete(baseURL+"/archives/1", "archive", "")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				So(archiver.archived["1"], ShouldBeTrue)
+
+				doc, resp, err := jsc.List(baseURL, "archives/archived")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(len(doc.Data), ShouldEqual, 1)
+
+				_, resp, err = jsc.Action(baseURL, "archives", "1", "unarchive", nil)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				So(archiver.archived["1"], ShouldBeFalse)
+			})
+
+			Convey("should not shadow the archived list", func() {
+				So(archiveResource.ValidateRouteOrder(), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestValidatedPost(t *testing.T) {
+	var savedDryRun []bool
+	validatedResource := NewResource("validateds")
+	validatedResource.ValidatedPost(func(ctx context.Context, object *jsh.Object, dryRun bool) (*jsh.Object, jsh.ErrorType) {
+		savedDryRun = append(savedDryRun, dryRun)
+		if !dryRun {
+			object.ID = "1"
+		}
+		return object, nil
+	}, true)
+
+	api := New("")
+	api.Add(validatedResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Validated Post Tests", t, func() {
+
+		Convey("->ValidatedPost()", func() {
+			savedDryRun = nil
+
+			Convey("should only validate with the dry run header", func() {
+				request, err := jsc.PostRequest(baseURL, sampleObject("", "validateds", testObjAttrs))
+				So(err, ShouldBeNil)
+				request.Header.Set(DryRunHeader, "true")
+				doc, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].Type, ShouldEqual, "validateds")
+				So(savedDryRun, ShouldResemble, []bool{true})
+			})
+
+			Convey("should save otherwise", func() {
+				doc, resp, err := jsc.Post(baseURL, sampleObject("", "validateds", testObjAttrs))
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+				So(doc.Data[0].ID, ShouldEqual, "1")
+				So(savedDryRun, ShouldResemble, []bool{false})
+			})
+		})
+	})
+}
+
+func TestCount(t *testing.T) {
+	resource := NewMockResource(testResourceType, 2, testObjAttrs)
+
+	countResource := NewCRUDResource("counts", &MockStorage{ResourceType: "counts", ResourceAttributes: testObjAttrs})
+	countResource.Count(mockCounter(42), true)
+
+	api := New("")
+	api.Add(resource)
+	api.Add(countResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Count Tests", t, func() {
+
+		Convey("->SetDryRunHeader()", func() {
+			dryRunCount := func(resourceType string) interface{} {
+				request, err := jsc.ListRequest(baseURL, resourceType)
+				So(err, ShouldBeNil)
+				request.Header.Set("X-Dry-Run", "true")
+				resp, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+				body := map[string]interface{}{}
+				So(json.NewDecoder(resp.Body).Decode(&body), ShouldBeNil)
+				So(body["data"], ShouldResemble, []interface{}{})
+				return body["meta"].(map[string]interface{})["count"]
+			}
+
+			Convey("should count with the counter of the resource", func() {
+				countResource.SetDryRunHeader("X-Dry-Run")
+				defer countResource.SetDryRunHeader("")
+
+				So(dryRunCount("counts"), ShouldEqual, 42)
+			})
+
+			Convey("should not list the objects without counter", func() {
+				resource.SetDryRunHeader("X-Dry-Run")
+				defer resource.SetDryRunHeader("")
+
+				request, err := jsc.ListRequest(baseURL, testResourceType)
+				So(err, ShouldBeNil)
+				request.Header.Set("X-Dry-Run", "true")
+				_, resp, err := jsc.Do(request, jsh.ListMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusNotImplemented)
+			})
+		})
+
+		Convey("->Count()", func() {
+			resp, err := http.Get(baseURL + "/counts/count")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			body := map[string]interface{}{}
+			So(json.NewDecoder(resp.Body).Decode(&body), ShouldBeNil)
+			So(body["meta"], ShouldResemble, map[string]interface{}{"count": float64(42)})
+			_, hasData := body["data"]
+			So(hasData, ShouldBeFalse)
+			So(countResource.ValidateRouteOrder(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestGetFields(t *testing.T) {
+	fieldGetter := &mockFieldGetter{MockStorage: MockStorage{ResourceType: "fieldeds", ResourceAttributes: testObjAttrs}}
+	fieldResource := NewCRUDResource("fieldeds", fieldGetter)
+
+	api := New("")
+	api.Add(fieldResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Get Fields Tests", t, func() {
+
+		Convey("->GetFields()", func() {
+			fieldGetter.fields = nil
+
+			Convey("should fetch the fields of the sparse fieldset", func() {
+				request, err := jsc.FetchRequest(baseURL, "fieldeds", "1")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "fields[fieldeds]=foo"
+				doc, resp, err := jsc.Do(request, jsh.ObjectMode)
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].ID, ShouldEqual, "1")
+				So(fieldGetter.fields, ShouldResemble, []string{"foo"})
+			})
+
+			Convey("should fall back to Get without sparse fieldset", func() {
+				_, resp, err := jsc.Fetch(baseURL, "fieldeds", "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(fieldGetter.fields, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestRandom(t *testing.T) {
+	randomResource := NewResource("randoms")
+	randomResource.Random(func(ctx context.Context) (*jsh.Object, jsh.ErrorType) {
+		return sampleObject("7", "randoms", testObjAttrs), nil
+	}, true)
+	randomResource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return sampleObject(id, "randoms", testObjAttrs), nil
+	}, true)
+
+	crudRandomResource := NewCRUDResource("crudrandoms", &MockStorage{ResourceType: "crudrandoms", ResourceAttributes: testObjAttrs})
+	crudRandomResource.Random(func(ctx context.Context) (*jsh.Object, jsh.ErrorType) {
+		return sampleObject("7", "crudrandoms", testObjAttrs), nil
+	}, true)
+
+	api := New("")
+	api.Add(randomResource)
+	api.Add(crudRandomResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("Random Tests", t, func() {
+
+		Convey("->Random()", func() {
+
+			Convey("should send a random object", func() {
+				doc, resp, err := jsc.Fetch(baseURL, "randoms", "random")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].ID, ShouldEqual, "7")
+				So(randomResource.ValidateRouteOrder(), ShouldBeEmpty)
+			})
+
+			Convey("should redirect to the random object", func() {
+				randomResource.SetRandomRedirect(true)
+				defer randomResource.SetRandomRedirect(false)
+
+				client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				}}
+				resp, err := client.Get(baseURL + "/randoms/random")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusSeeOther)
+				So(resp.Header.Get("Location"), ShouldEqual, "/randoms/7")
+			})
+
+			Convey("should not be shadowed by the CRUD routes registered before it", func() {
+				doc, resp, err := jsc.Fetch(baseURL, "crudrandoms", "random")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].ID, ShouldEqual, "7")
+				So(crudRandomResource.ValidateRouteOrder(), ShouldBeEmpty)
+
+				doc, resp, err = jsc.Fetch(baseURL, "crudrandoms", "3")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].ID, ShouldEqual, "3")
+			})
+		})
+	})
+}
+
+func TestListWithIncludes(t *testing.T) {
+	includeResource := NewResource("includes")
+	includeResource.ListWithIncludes(func(ctx context.Context) (jsh.List, map[string]jsh.List, jsh.ErrorType) {
+		return jsh.List{sampleObject("1", "includes", testObjAttrs), sampleObject("2", "includes", testObjAttrs)},
+			map[string]jsh.List{
+				"author": {sampleObject("1", "authors", testObjAttrs), sampleObject("1", "authors", testObjAttrs)},
+				"tags":   {sampleObject("1", "tags", testObjAttrs)},
+			}, nil
+	}, true)
+
+	api := New("")
+	api.Add(includeResource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL
+
+	Convey("List With Includes Tests", t, func() {
+
+		Convey("->ListWithIncludes()", func() {
+
+			Convey("should include the related objects once", func() {
+				doc, resp, err := jsc.List(baseURL, "includes")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(len(doc.Data), ShouldEqual, 2)
+				So(len(doc.Included), ShouldEqual, 2)
+				So(doc.Included[0].Type, ShouldEqual, "authors")
+				So(doc.Included[1].Type, ShouldEqual, "tags")
+			})
+
+			Convey("should only include the requested relationships", func() {
+				request, err := jsc.ListRequest(baseURL, "includes")
+				So(err, ShouldBeNil)
+				request.URL.RawQuery = "include=tags"
+				doc, _, err := jsc.Do(request, jsh.ListMode)
+
+				So(err, ShouldBeNil)
+				So(len(doc.Included), ShouldEqual, 1)
+				So(doc.Included[0].Type, ShouldEqual, "tags")
+			})
 		})
+	})
+}
 
-		Convey("->List()", func() {
-			doc, resp, err := jsc.List(baseURL, testResourceType)
+// mockFieldGetter records the fields passed to GetWithFields.
+type mockFieldGetter struct {
+	MockStorage
+	fields []string
+}
 
-			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+func (m *mockFieldGetter) GetWithFields(ctx context.Context, id string, fields []string) (*jsh.Object, jsh.ErrorType) {
+	m.fields = fields
+	return m.Get(ctx, id)
+}
+
+// mockSQLError is a storage error wrapping a database/sql error.
+type mockSQLError struct {
+	cause error
+}
+
+func (e *mockSQLError) Error() string                                      { return e.cause.Error() }
+func (e *mockSQLError) Validate(r *http.Request, response bool) *jsh.Error { return nil }
+func (e *mockSQLError) StatusCode() int                                    { return http.StatusInternalServerError }
+func (e *mockSQLError) Unwrap() error                                      { return e.cause }
+
+// mockChunkedList lists its objects a chunk at a time, starting over once all were listed.
+// It fails with err instead if set.
+type mockChunkedList struct {
+	objects jsh.List
+	offset  int
+	err     jsh.ErrorType
+}
+
+func (l *mockChunkedList) NextChunk(ctx context.Context, chunkSize int) (jsh.List, bool, jsh.ErrorType) {
+	if l.err != nil {
+		return nil, false, l.err
+	}
+	end := l.offset + chunkSize
+	if end > len(l.objects) {
+		end = len(l.objects)
+	}
+	chunk := l.objects[l.offset:end]
+	l.offset = end
+	if l.offset == len(l.objects) {
+		l.offset = 0
+		return chunk, false, nil
+	}
+	return chunk, true, nil
+}
+
+// mockStructuredLogger records the requests logged, without duration.
+type mockStructuredLogger struct {
+	entries []string
+}
+
+func (l *mockStructuredLogger) LogRequest(resourceType, method, id string, status int, duration time.Duration) {
+	l.entries = append(l.entries, fmt.Sprintf("%s %s %s %d", resourceType, method, id, status))
+}
+
+// mockCounter counts a fixed number of objects.
+type mockCounter int
+
+func (c mockCounter) Count(ctx context.Context) (int, jsh.ErrorType) {
+	return int(c), nil
+}
+
+// mockArchiver keeps track of the archived object IDs.
+type mockArchiver struct {
+	MockStorage
+	archived map[string]bool
+}
+
+func (m *mockArchiver) Archive(ctx context.Context, id string) jsh.ErrorType {
+	m.archived[id] = true
+	return nil
+}
+
+func (m *mockArchiver) Unarchive(ctx context.Context, id string) jsh.ErrorType {
+	m.archived[id] = false
+	return nil
+}
+
+func (m *mockArchiver) ListArchived(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	list := jsh.List{}
+	for id, archived := range m.archived {
+		if !archived {
+			continue
+		}
+		list = append(list, sampleObject(id, "archives", testObjAttrs))
+	}
+	return list, nil
+}
+
+// mockTrackingUpdater reports the attributes of the patched object as changed.
+type mockTrackingUpdater struct {
+	MockStorage
+}
+
+func (m *mockTrackingUpdater) TrackingUpdate(ctx context.Context, object *jsh.Object) (*jsh.Object, []string, jsh.ErrorType) {
+	return object, []string{"foo"}, nil
+}
+
+// mockDiffUpdater records the current object passed to DiffUpdate.
+type mockDiffUpdater struct {
+	MockStorage
+	old *jsh.Object
+}
+
+func (m *mockDiffUpdater) DiffUpdate(ctx context.Context, old, new *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	m.old = old
+	return new, nil
+}
+
+// mockAuditor records the events emitted for a mock resource.
+type mockAuditor struct {
+	MockStorage
+	events []store.CloudEvent
+}
+
+func (m *mockAuditor) Emit(ctx context.Context, event store.CloudEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	auditor := &mockAuditor{MockStorage: MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}}
+	resource := NewCRUDResource(testResourceType, auditor)
+
+	api := New("api")
+	api.Add(resource)
+
+	server := httptest.NewServer(api)
+	baseURL := server.URL + "/api"
+
+	Convey("Audit Tests", t, func() {
+		auditor.events = nil
+
+		Convey("should emit an event after a save", func() {
+			_, _, err := jsc.Post(baseURL, sampleObject("", testResourceType, testObjAttrs))
 			So(err, ShouldBeNil)
-			So(len(doc.Data), ShouldEqual, 2)
-			So(doc.Data[0].ID, ShouldEqual, "1")
+
+			So(len(auditor.events), ShouldEqual, 1)
+			So(auditor.events[0].Type, ShouldEqual, store.EventSaved)
+			So(auditor.events[0].Source, ShouldEqual, "/api/bars")
+			So(auditor.events[0].ID, ShouldNotBeEmpty)
 		})
 
-		Convey("->Fetch()", func() {
-			doc, resp, err := jsc.Fetch(baseURL, testResourceType, "3")
+		Convey("should emit an event after a delete", func() {
+			_, err := jsc.Delete(baseURL, testResourceType, "1")
+			So(err, ShouldBeNil)
 
-			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(len(auditor.events), ShouldEqual, 1)
+			So(auditor.events[0].Type, ShouldEqual, store.EventDeleted)
+			So(auditor.events[0].Source, ShouldEqual, "/api/bars")
+		})
+
+		Convey("should not emit events for reads", func() {
+			_, _, err := jsc.Fetch(baseURL, testResourceType, "1")
 			So(err, ShouldBeNil)
-			So(doc.Data[0].ID, ShouldEqual, "3")
+			So(auditor.events, ShouldBeEmpty)
 		})
+	})
+}
 
-		Convey("->Patch()", func() {
+func TestSearch(t *testing.T) {
+	var query string
+	var filters store.FilterMap
+	mock := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 2}
 
-			Convey("should reject requests with ID mismatch", func() {
-				object := sampleObject("1", testResourceType, testObjAttrs)
-				request, err := jsc.PatchRequest(baseURL, object)
-				So(err, ShouldBeNil)
-				// Manually replace resource ID in URL to be invalid
-				request.URL.Path = strings.Replace(request.URL.Path, "1", "2", 1)
-				doc, resp, err := jsc.Do(request, jsh.ObjectMode)
+	resource := NewResource(testResourceType)
+	resource.Search(func(ctx context.Context, q string, f store.FilterMap) (jsh.List, jsh.ErrorType) {
+		query, filters = q, f
+		return mock.SampleList(1), nil
+	}, true)
+	resource.CRUD(mock)
 
-				So(resp.StatusCode, ShouldEqual, 409)
-				So(err, ShouldBeNil)
-				So(doc, ShouldNotBeNil)
-			})
+	api := New("")
+	api.Add(resource)
 
-			Convey("should accept patch requests", func() {
-				object := sampleObject("1", testResourceType, testObjAttrs)
-				doc, resp, err := jsc.Patch(baseURL, object)
+	server := httptest.NewServer(api)
+	baseURL := server.URL
 
-				So(resp.StatusCode, ShouldEqual, http.StatusOK)
-				So(err, ShouldBeNil)
-				So(doc.Data[0].ID, ShouldEqual, "1")
-			})
+	Convey("Search Tests", t, func() {
+
+		Convey("->Search()", func() {
+			request, err := jsc.ListRequest(baseURL, testResourceType+"/search")
+			So(err, ShouldBeNil)
+			request.URL.RawQuery = "q=foo&filter[name]=bar,baz"
+			doc, resp, err := jsc.Do(request, jsh.ListMode)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(len(doc.Data), ShouldEqual, 1)
+			So(query, ShouldEqual, "foo")
+			So(filters, ShouldResemble, store.FilterMap{"name": {"bar", "baz"}})
 		})
 
-		Convey("->Delete()", func() {
-			resp, err := jsc.Delete(baseURL, testResourceType, "1")
+		Convey("should not conflict with ->Get()", func() {
+			doc, resp, err := jsc.Fetch(baseURL, testResourceType, "3")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Data[0].ID, ShouldEqual, "3")
+		})
+
+		Convey("should not be shadowed by the CRUD routes registered before it", func() {
+			crudResource := NewCRUDResource("crudsearches", &MockStorage{ResourceType: "crudsearches", ResourceAttributes: testObjAttrs})
+			crudResource.Search(func(ctx context.Context, q string, f store.FilterMap) (jsh.List, jsh.ErrorType) {
+				query = q
+				return jsh.List{}, nil
+			}, true)
+			crudAPI := New("")
+			crudAPI.Add(crudResource)
+			crudServer := httptest.NewServer(crudAPI)
+			defer crudServer.Close()
+
+			request, err := jsc.ListRequest(crudServer.URL, "crudsearches/search")
+			So(err, ShouldBeNil)
+			request.URL.RawQuery = "q=x"
+			doc, resp, err := jsc.Do(request, jsh.ListMode)
 
-			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
 			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Data, ShouldBeEmpty)
+			So(query, ShouldEqual, "x")
+			So(crudResource.ValidateRouteOrder(), ShouldBeEmpty)
 		})
 	})
 }
@@ -117,8 +2130,57 @@ func TestActionHandler(t *testing.T) {
 	}
 	resource.Action("testAction", handler, true)
 
+	// Add a relationship action on another resource
+	relResource := NewMockResource("foos", 2, testObjAttrs)
+	relHandler := func(ctx context.Context, id string, list jsh.IDList,
+		w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+		object := sampleObject(id, "foos", testObjAttrs)
+		object.Meta = map[string]interface{}{"count": len(list)}
+		return object, nil
+	}
+	relResource.RelationshipAction("assign", relHandler, true)
+
+	// Add an idempotent action counting its calls
+	var calls int
+	countHandler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType) {
+		calls++
+		object := sampleObject(pat.Param(ctx, "id"), "foos", testObjAttrs)
+		object.Meta = map[string]interface{}{"calls": calls}
+		return object, nil
+	}
+	cache := store.NewInMemoryIdempotencyCache(time.Minute)
+	relResource.IdempotentAction("count", countHandler, cache, true)
+
+	// Add a streaming action waiting for the client to read each update before sending the next
+	next := make(chan struct{})
+	streamHandler := func(ctx context.Context, id string, w http.ResponseWriter, r *http.Request) jsh.ErrorType {
+		for step := 1; step <= 3; step++ {
+			fmt.Fprintf(w, "{\"id\":\"%s\",\"step\":%d}\n", id, step)
+			w.(http.Flusher).Flush()
+			<-next
+		}
+		return nil
+	}
+	relResource.StreamingAction("import", streamHandler, true)
+	relResource.StreamingAction("fail", func(ctx context.Context, id string, w http.ResponseWriter, r *http.Request) jsh.ErrorType {
+		return jsh.ISE("import failed")
+	}, true)
+
+	relResource.ListAction("duplicates", func(ctx context.Context, w http.ResponseWriter, r *http.Request) (jsh.List, jsh.ErrorType) {
+		id := pat.Param(ctx, "id")
+		if id == "0" {
+			return nil, jsh.NotFound("foos", id)
+		}
+		return jsh.List{sampleObject("2", "foos", testObjAttrs), sampleObject("3", "foos", testObjAttrs)}, nil
+	}, true)
+
+	relResource.Command("run", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return sampleObject(id, "foos", testObjAttrs), nil
+	}, true)
+
 	api := New("")
 	api.Add(resource)
+	api.Add(relResource)
 
 	server := httptest.NewServer(api)
 	baseURL := server.URL
@@ -128,6 +2190,22 @@ func TestActionHandler(t *testing.T) {
 		Convey("Resource State", func() {
 			So(len(resource.Routes), ShouldEqual, 10)
 			So(resource.Routes[len(resource.Routes)-1].String(), ShouldEqual, "POST    - /bars/:id/testAction")
+			So(resource.Actions["testAction"], ShouldResemble, resource.Routes[len(resource.Routes)-1])
+			So(resource.RouteTree(), ShouldContainSubstring, "\n[ACTION] POST    - /bars/:id/testAction")
+		})
+
+		Convey("->Format()", func() {
+			route := Route{Method: get, Path: "/bars"}
+			So(route.Format(5, 8), ShouldEqual, "GET   - /bars   ")
+			So(route.Format(0, 0), ShouldEqual, "GET - /bars")
+		})
+
+		Convey("->RouteTree() should align paths on the action label and the longest method", func() {
+			resource := NewResource("users")
+			resource.Get(nil, true)
+			resource.Action("reset", nil, true)
+
+			So(resource.RouteTree(), ShouldEqual, "\n         HEAD - /users/:id\n         GET  - /users/:id\n[ACTION] POST - /users/:id/reset")
 		})
 
 		Convey("->Custom()", func() {
@@ -137,6 +2215,105 @@ func TestActionHandler(t *testing.T) {
 			So(response.StatusCode, ShouldEqual, http.StatusOK)
 			So(doc.Data, ShouldNotBeEmpty)
 		})
+
+		Convey("->ListAction()", func() {
+			So(relResource.Actions["duplicates"].String(), ShouldEqual, "POST    - /foos/:id/duplicates")
+
+			request, err := jsc.ActionRequest(baseURL, "foos", "1", "duplicates", nil)
+			So(err, ShouldBeNil)
+			doc, response, err := jsc.Do(request, jsh.ListMode)
+			So(err, ShouldBeNil)
+			So(response.StatusCode, ShouldEqual, http.StatusOK)
+			So(len(doc.Data), ShouldEqual, 2)
+
+			_, response, err = jsc.Action(baseURL, "foos", "0", "duplicates", nil)
+			So(err, ShouldBeNil)
+			So(response.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->IdempotentAction()", func() {
+			calls = 0
+			send := func(key string) *jsh.Document {
+				request, err := jsc.ActionRequest(baseURL, "foos", "1", "count", nil)
+				So(err, ShouldBeNil)
+				request.Header.Set("Idempotency-Key", key)
+				doc, response, err := jsc.Do(request, jsh.ObjectMode)
+				So(err, ShouldBeNil)
+				So(response.StatusCode, ShouldEqual, http.StatusOK)
+				return doc
+			}
+
+			So(send("a").Data[0].Meta["calls"], ShouldEqual, 1)
+			So(send("a").Data[0].Meta["calls"], ShouldEqual, 1)
+			So(send("b").Data[0].Meta["calls"], ShouldEqual, 2)
+		})
+
+		Convey("->Command()", func() {
+			request, err := http.NewRequest("POST", baseURL+"/foos/1/run", nil)
+			So(err, ShouldBeNil)
+			doc, response, err := jsc.Do(request, jsh.ObjectMode)
+
+			So(err, ShouldBeNil)
+			So(response.StatusCode, ShouldEqual, http.StatusOK)
+			So(doc.Data[0].ID, ShouldEqual, "1")
+		})
+
+		Convey("->StreamingAction()", func() {
+
+			Convey("should flush each update as a separate chunk", func() {
+				request, err := jsc.ActionRequest(baseURL, "foos", "1", "import", nil)
+				So(err, ShouldBeNil)
+				response, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				defer response.Body.Close()
+
+				So(response.StatusCode, ShouldEqual, http.StatusOK)
+				So(response.Header.Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+				So(response.TransferEncoding, ShouldResemble, []string{"chunked"})
+
+				reader := bufio.NewReader(response.Body)
+				for step := 1; step <= 3; step++ {
+					line, err := reader.ReadString('\n')
+					So(err, ShouldBeNil)
+					So(line, ShouldEqual, fmt.Sprintf("{\"id\":\"1\",\"step\":%d}\n", step))
+					next <- struct{}{}
+				}
+			})
+
+			Convey("should write the storage error as the last line", func() {
+				request, err := jsc.ActionRequest(baseURL, "foos", "1", "fail", nil)
+				So(err, ShouldBeNil)
+				response, err := http.DefaultClient.Do(request)
+				So(err, ShouldBeNil)
+				defer response.Body.Close()
+
+				body, err := ioutil.ReadAll(response.Body)
+				So(err, ShouldBeNil)
+				So(string(body), ShouldContainSubstring, `"errors"`)
+				So(string(body), ShouldEndWith, "\n")
+			})
+		})
+
+		Convey("->RelationshipAction()", func() {
+
+			Convey("should pass the parsed list to storage", func() {
+				list := jsh.IDList{jsh.NewIDObject(testResourceType, "1"), jsh.NewIDObject(testResourceType, "2")}
+				doc, response, err := jsc.Action(baseURL, "foos", "1", "assign", list)
+
+				So(err, ShouldBeNil)
+				So(response.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].Meta["count"], ShouldEqual, 2)
+			})
+
+			Convey("should reject an invalid body", func() {
+				request, err := jsc.ActionRequest(baseURL, "foos", "1", "assign", nil)
+				So(err, ShouldBeNil)
+				request.Body = jsh.CreateReadCloser([]byte("{"))
+				_, response, _ := jsc.Do(request, jsh.ObjectMode)
+
+				So(response.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
 	})
 }
 
@@ -163,6 +2340,16 @@ func TestToOne(t *testing.T) {
 				So(len(resource.Relationships), ShouldEqual, 1)
 				So(len(resource.Routes), ShouldEqual, 16)
 			})
+
+			Convey("should include relationship routes in the route tree", func() {
+				tree := resource.RouteTree()
+				So(strings.Count(tree, "\n"), ShouldEqual, 16)
+				for _, route := range resource.Routes {
+					So(tree, ShouldContainSubstring, route.String())
+				}
+				So(tree, ShouldContainSubstring, "GET     - /bars/:id/bar")
+				So(tree, ShouldContainSubstring, "PATCH   - /bars/:id/relationships/bar")
+			})
 		})
 
 		Convey("->ToOne()", func() {
@@ -177,6 +2364,81 @@ func TestToOne(t *testing.T) {
 				So(doc.Data[0].Attributes, ShouldNotBeEmpty)
 			})
 
+			Convey("->EnableSelfLinks()", func() {
+				resource.EnableSelfLinks()
+				defer func() { resource.selfLinks = false }()
+
+				doc, resp, err := jsc.Fetch(baseURL, testResourceType, "1")
+
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(doc.Data[0].Links["self"].HREF, ShouldEqual, "/bars/1")
+				links := doc.Data[0].Relationships["bar"].Links
+				So(links.Self.HREF, ShouldEqual, "/bars/1/relationships/bar")
+				So(links.Related.HREF, ShouldEqual, "/bars/1/bar")
+			})
+
+			Convey("->EnableHTTP2Hints()", func() {
+				resource.EnableHTTP2Hints()
+				defer func() { resource.http2Hints = false }()
+
+				http2Server := httptest.NewUnstartedServer(api)
+				http2Server.EnableHTTP2 = true
+				http2Server.StartTLS()
+				defer http2Server.Close()
+
+				Convey("should add preload hints over HTTP/2", func() {
+					request, err := jsc.FetchRequest(http2Server.URL, testResourceType, "1")
+					So(err, ShouldBeNil)
+					resp, err := http2Server.Client().Do(request)
+
+					So(err, ShouldBeNil)
+					So(resp.ProtoMajor, ShouldEqual, 2)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+					So(resp.Header.Get("Link"), ShouldEqual, "</bars/1/bar>; rel=preload")
+				})
+
+				Convey("should add preload hints through the status recording writer", func() {
+					resource.EnableStructuredLogging(&mockStructuredLogger{})
+					defer resource.EnableStructuredLogging(nil)
+
+					request, err := jsc.FetchRequest(http2Server.URL, testResourceType, "1")
+					So(err, ShouldBeNil)
+					resp, err := http2Server.Client().Do(request)
+
+					So(err, ShouldBeNil)
+					So(resp.ProtoMajor, ShouldEqual, 2)
+					So(resp.Header.Get("Link"), ShouldEqual, "</bars/1/bar>; rel=preload")
+				})
+
+				Convey("should skip hints over HTTP/1", func() {
+					_, resp, err := jsc.Fetch(baseURL, testResourceType, "1")
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+					So(resp.Header.Get("Link"), ShouldBeEmpty)
+				})
+
+				Convey("should skip hints over HTTP/1 through the status recording writer", func() {
+					resource.EnableStructuredLogging(&mockStructuredLogger{})
+					defer resource.EnableStructuredLogging(nil)
+
+					_, resp, err := jsc.Fetch(baseURL, testResourceType, "1")
+
+					So(err, ShouldBeNil)
+					So(resp.Header.Get("Link"), ShouldBeEmpty)
+				})
+			})
+
+			Convey("MockToOneStorage", func() {
+				toOne.CallLog = nil
+
+				_, _, err := jsc.FetchRelationship(baseURL, testResourceType, "1", "bar")
+				So(err, ShouldBeNil)
+				So(toOne.CallLog, ShouldResemble, []MockCall{{Method: "Get", Args: []interface{}{"1"}}})
+				So(toOne.Ping(context.Background()), ShouldBeNil)
+			})
+
 			Convey("->Get()", func() {
 				doc, resp, err := jsc.FetchRelationship(baseURL, testResourceType, "1", "bar")
 
@@ -205,11 +2467,41 @@ func TestToOne(t *testing.T) {
 					So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
 					So(doc, ShouldBeNil)
 				})
+
+				Convey("should reject a document without data", func() {
+					request, err := http.NewRequest("PATCH", baseURL+"/bars/1/relationships/bar", strings.NewReader("{}"))
+					So(err, ShouldBeNil)
+					request.Header.Set("Content-Type", jsh.ContentType)
+					resp, err := http.DefaultClient.Do(request)
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+				})
 			})
 		})
 	})
 }
 
+// mockToManyCreator reports relationships to the resource with ID "1" as created.
+type mockToManyCreator struct {
+	MockToManyStorage
+}
+
+func (m *mockToManyCreator) Create(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, bool, jsh.ErrorType) {
+	return list, id == "1", nil
+}
+
+// mockToManyReplacer records the relationships replaced by PATCH requests.
+type mockToManyReplacer struct {
+	MockToManyStorage
+	replaced jsh.IDList
+}
+
+func (m *mockToManyReplacer) Replace(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType) {
+	m.replaced = list
+	return nil, nil
+}
+
 func TestToMany(t *testing.T) {
 	resource := NewMockResource(testResourceType, 2, testObjAttrs)
 
@@ -221,8 +2513,40 @@ func TestToMany(t *testing.T) {
 	}
 	resource.ToMany(relResourceType, toMany)
 
+	creatorResource := NewMockResource("creators", 2, testObjAttrs)
+	creatorResource.ToMany(relResourceType, &mockToManyCreator{})
+
+	var operation store.ToManyOperation
+	recordOperation := func(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType) {
+		operation = store.ToManyOperationFromContext(ctx)
+		return list, nil
+	}
+	replacer := &mockToManyReplacer{}
+	replacerResource := NewMockResource("replacers", 2, testObjAttrs)
+	replacerResource.ToMany(relResourceType, replacer)
+
+	operationResource := NewResource("operations")
+	operationResource.PostMany(recordOperation, "/:id/relationships/bars", true)
+	operationResource.PatchMany(recordOperation, "/:id/relationships/bars", true)
+	operationResource.DeleteMany(recordOperation, "/:id/relationships/bars", true)
+
+	extendedResource := NewResource("extended")
+	extendedResource.PatchMany(func(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType) {
+		return append(list, jsh.NewIDObject(relResourceType, "2")), nil
+	}, "/:id/relationships/bars", true)
+
+	tagsResource := NewMockResource("users", 2, testObjAttrs)
+	tagsResource.DynamicToMany("tags/:tagType", func(ctx context.Context, parentID, relParam string) (jsh.List, jsh.ErrorType) {
+		return jsh.List{sampleObject(relParam, "tags", map[string]string{"user": parentID})}, nil
+	}, true)
+
 	api := New("")
 	api.Add(resource)
+	api.Add(creatorResource)
+	api.Add(replacerResource)
+	api.Add(operationResource)
+	api.Add(extendedResource)
+	api.Add(tagsResource)
 
 	server := httptest.NewServer(api)
 	baseURL := server.URL
@@ -237,6 +2561,32 @@ func TestToMany(t *testing.T) {
 			})
 		})
 
+		Convey("->DynamicToMany()", func() {
+			doc, resp, err := jsc.FetchRelated(baseURL, "users", "1", "tags/system")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(len(doc.Data), ShouldEqual, 1)
+			So(doc.Data[0].ID, ShouldEqual, "system")
+			So(doc.Data[0].Type, ShouldEqual, "tags")
+		})
+
+		Convey("should pass the to-many operation to storage", func() {
+			list := jsh.IDList{jsh.NewIDObject(relResourceType, "1")}
+
+			_, _, err := jsc.PostMany(baseURL, "operations", "1", relResourceType, list)
+			So(err, ShouldBeNil)
+			So(operation, ShouldEqual, store.ToManyAdd)
+
+			_, _, err = jsc.PatchMany(baseURL, "operations", "1", relResourceType, list)
+			So(err, ShouldBeNil)
+			So(operation, ShouldEqual, store.ToManyReplace)
+
+			_, _, err = jsc.DeleteMany(baseURL, "operations", "1", relResourceType, list)
+			So(err, ShouldBeNil)
+			So(operation, ShouldEqual, store.ToManyRemove)
+		})
+
 		Convey("->ToMany()", func() {
 
 			Convey("->ListResources()", func() {
@@ -279,6 +2629,23 @@ func TestToMany(t *testing.T) {
 					So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
 					So(doc, ShouldNotBeNil)
 				})
+
+				Convey("should respond with 201 when created", func() {
+					object := jsh.NewIDObject(relResourceType, "1")
+					doc, resp, err := jsc.PostMany(baseURL, "creators", "1", "bars", jsh.IDList{object})
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+					So(len(doc.Data), ShouldEqual, 1)
+				})
+
+				Convey("should respond with 200 when updated", func() {
+					object := jsh.NewIDObject(relResourceType, "1")
+					_, resp, err := jsc.PostMany(baseURL, "creators", "2", "bars", jsh.IDList{object})
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				})
 			})
 
 			Convey("->Patch()", func() {
@@ -299,6 +2666,25 @@ func TestToMany(t *testing.T) {
 					So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
 					So(doc, ShouldBeNil)
 				})
+
+				Convey("should replace the list with a ToManyReplacer storage", func() {
+					list := jsh.IDList{jsh.NewIDObject(relResourceType, "1"), jsh.NewIDObject(relResourceType, "2")}
+					_, resp, err := jsc.PatchMany(baseURL, "replacers", "1", "bars", list)
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+					So(len(replacer.replaced), ShouldEqual, 2)
+				})
+
+				Convey("should respond with 200 and the list extended by storage", func() {
+					object := jsh.NewIDObject(relResourceType, "1")
+					doc, resp, err := jsc.PatchMany(baseURL, "extended", "1", "bars", jsh.IDList{object})
+
+					So(err, ShouldBeNil)
+					So(resp.StatusCode, ShouldEqual, http.StatusOK)
+					So(len(doc.Data), ShouldEqual, 2)
+					So(doc.Data[1].ID, ShouldEqual, "2")
+				})
 			})
 
 			Convey("->Delete()", func() {
@@ -0,0 +1,120 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+/*
+ValidateRouteOrder returns a warning for each route of the resource that can never be
+matched because goji, which matches routes in registration order, would match a previously
+registered wildcard route first. For instance, `GET /resources/:id/tags/system` is shadowed
+by `GET /resources/:id/tags/:tagType` if registered after it. The static GET routes, such as
+`GET /resources/search`, are never shadowed by the /:id routes.
+
+In debug mode, warnings are logged when the resource is added to the API and after CRUD,
+ToOne and ToMany register their routes.
+*/
+func (res *Resource) ValidateRouteOrder() []string {
+	var warnings []string
+	for i, route := range res.Routes {
+		if res.isStatic(route) {
+			continue
+		}
+		for _, previous := range res.Routes[:i] {
+			if previous.Method == route.Method && shadows(previous.Path, route.Path) {
+				warnings = append(warnings, fmt.Sprintf(
+					"%s %s is shadowed by %s %s registered before it",
+					route.Method, route.Path, previous.Method, previous.Path,
+				))
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// logRouteOrder logs the warnings of ValidateRouteOrder in debug mode.
+func (res *Resource) logRouteOrder() {
+	if !res.debug {
+		return
+	}
+	for _, warning := range res.ValidateRouteOrder() {
+		Logger.Printf("%s\n", warning)
+	}
+}
+
+// shadows returns true if the pattern matches every path matched by the specific pattern,
+// while using a wildcard where the specific pattern uses a static segment.
+func shadows(pattern string, specific string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		prefix := strings.TrimSuffix(pattern, "*")
+		return strings.HasPrefix(specific, prefix) && specific != pattern
+	}
+
+	segments := strings.Split(pattern, "/")
+	specificSegments := strings.Split(specific, "/")
+	if len(segments) != len(specificSegments) {
+		return false
+	}
+
+	wildcard := false
+	for i, segment := range segments {
+		switch {
+		case segment == specificSegments[i]:
+		case strings.HasPrefix(segment, ":") && !strings.HasPrefix(specificSegments[i], ":"):
+			wildcard = true
+		default:
+			return false
+		}
+	}
+	return wildcard
+}
+
+// handleStatic registers a GET handler for a static path of the resource, such as /search.
+// The /:id routes do not match the static paths, so that the handler is not shadowed by
+// them whatever the registration order.
+func (res *Resource) handleStatic(route string, handler func(context.Context, http.ResponseWriter, *http.Request)) {
+	pattern := pat.Get(route)
+	res.staticRoutes = append(res.staticRoutes, pattern)
+	res.HandleFuncC(pattern, handler)
+}
+
+// isStatic returns true if the route is a GET or HEAD route registered by handleStatic.
+func (res *Resource) isStatic(route Route) bool {
+	if route.Method != get && route.Method != head {
+		return false
+	}
+	for _, static := range res.staticRoutes {
+		if route.Path == fmt.Sprintf("/%s%s", res.Type, static) {
+			return true
+		}
+	}
+	return false
+}
+
+// idPattern wraps a /:id pattern of the resource so that it does not match the static routes.
+func (res *Resource) idPattern(pattern *pat.Pattern) goji.Pattern {
+	return &idPattern{Pattern: pattern, res: res}
+}
+
+// idPattern is a /:id pattern of a resource, not matching the static routes of the resource.
+type idPattern struct {
+	*pat.Pattern
+	res *Resource
+}
+
+// Match implements goji.Pattern.
+func (p *idPattern) Match(ctx context.Context, r *http.Request) context.Context {
+	for _, static := range p.res.staticRoutes {
+		if static.Match(ctx, r) != nil {
+			return nil
+		}
+	}
+	return p.Pattern.Match(ctx, r)
+}
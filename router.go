@@ -0,0 +1,44 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"goji.io"
+	"golang.org/x/net/context"
+)
+
+/*
+Router is the subset of the goji.Mux API used by an API to register its routes and
+middleware. It allows adapters for other routers to be used in place of goji, see
+NewAPIWithRouter.
+*/
+type Router interface {
+	HandleC(pattern goji.Pattern, handler goji.Handler)
+	UseC(middleware func(goji.Handler) goji.Handler)
+}
+
+// goji.Mux is the default router of an API
+var _ Router = (*goji.Mux)(nil)
+
+// ServeHTTP dispatches the request to the goji.Mux of the API, or to its custom router.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.Mux != nil {
+		a.Mux.ServeHTTP(w, r)
+		return
+	}
+	a.ServeHTTPC(context.TODO(), w, r)
+}
+
+// ServeHTTPC dispatches the request to the goji.Mux of the API, or to its custom router.
+// A 500 error is sent if the custom router is neither a goji.Handler nor an http.Handler.
+func (a *API) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	switch router := a.router.(type) {
+	case goji.Handler:
+		router.ServeHTTPC(ctx, w, r)
+	case http.Handler:
+		router.ServeHTTP(w, r)
+	default:
+		http.Error(w, fmt.Sprintf("jshapi: router %T cannot serve requests", a.router), http.StatusInternalServerError)
+	}
+}
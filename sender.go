@@ -1,6 +1,8 @@
 package jshapi
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"reflect"
 
@@ -26,6 +28,8 @@ func DefaultSender(logger std.Logger) Sender {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		sendable = addResponseMeta(ctx, r, sendable)
+
 		sendableError, isType := sendable.(jsh.ErrorType)
 		if isType && sendableError.StatusCode() >= 500 {
 			logger.Printf("Returning ISE: %s\n", sendableError.Error())
@@ -37,3 +41,83 @@ func DefaultSender(logger std.Logger) Sender {
 		}
 	}
 }
+
+// addResponseMeta merges the meta returned by the API response meta function, if
+// any, into the top-level meta of the document to send.
+func addResponseMeta(ctx context.Context, r *http.Request, sendable jsh.Sendable) jsh.Sendable {
+	metaFunc, _ := ctx.Value(responseMetaKey).(ResponseMetaFunc)
+	if metaFunc == nil {
+		return sendable
+	}
+	meta := metaFunc(ctx, r)
+	if len(meta) == 0 {
+		return sendable
+	}
+
+	// invalid payloads are left for jsh.Send to report
+	if err := sendable.Validate(r, true); err != nil {
+		return sendable
+	}
+
+	doc := jsh.Build(sendable)
+	merged, _ := doc.Meta.(map[string]interface{})
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+	for key, value := range meta {
+		merged[key] = value
+	}
+	doc.Meta = merged
+	return doc
+}
+
+// metaDocument builds a 200 document holding only a top-level meta. It uses the error mode
+// of jsh, the only one omitting the "data" member, without any error.
+func metaDocument(meta map[string]interface{}) *jsh.Document {
+	doc := jsh.New()
+	doc.Status = http.StatusOK
+	doc.Mode = jsh.ErrorMode
+	doc.Meta = meta
+	return doc
+}
+
+/*
+sendWithLinks sends the document with additional top-level links, keyed by relation such as
+"next", which jsh.Links cannot hold. The document is validated and gets the response meta
+like with the DefaultSender, errors being sent with the SendHandler.
+*/
+func sendWithLinks(ctx context.Context, w http.ResponseWriter, r *http.Request, doc *jsh.Document, links map[string]string) {
+	sendable := addResponseMeta(ctx, r, doc)
+	if err := sendable.Validate(r, true); err != nil {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+	doc = jsh.Build(sendable)
+
+	members := map[string]json.RawMessage{}
+	content, err := json.Marshal(doc)
+	if err == nil {
+		err = json.Unmarshal(content, &members)
+	}
+	if err != nil {
+		SendHandler(ctx, w, r, jsh.ISE(fmt.Sprintf("Unable to marshal JSON payload: %s", err)))
+		return
+	}
+
+	merged := map[string]*jsh.Link{}
+	if doc.Links != nil && doc.Links.Self != nil {
+		merged["self"] = doc.Links.Self
+	}
+	if doc.Links != nil && doc.Links.Related != nil {
+		merged["related"] = doc.Links.Related
+	}
+	for rel, href := range links {
+		merged[rel] = jsh.NewLink(href)
+	}
+	members["links"], _ = json.Marshal(merged)
+	content, _ = json.MarshalIndent(members, "", " ")
+
+	w.Header().Add("Content-Type", jsh.ContentType)
+	w.WriteHeader(doc.Status)
+	w.Write(content)
+}
@@ -0,0 +1,52 @@
+package jshapi
+
+import (
+	"net/http"
+	"time"
+)
+
+// Default timeouts of the servers started by ListenAndServe and ListenAndServeTLS.
+const (
+	DefaultReadTimeout  = 5 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultIdleTimeout  = 30 * time.Second
+)
+
+/*
+SetServerConfig replaces the server used by ListenAndServe and ListenAndServeTLS,
+along with its default timeouts. The address and handler of the server are set when
+it is started.
+
+The API is an http.Handler, so it can also be embedded in an existing server:
+
+	server := &http.Server{Addr: ":8080", Handler: api}
+*/
+func (a *API) SetServerConfig(server *http.Server) {
+	a.server = server
+}
+
+// ListenAndServe serves the API on the TCP network address addr, see http.Server.ListenAndServe.
+func (a *API) ListenAndServe(addr string) error {
+	return a.newServer(addr).ListenAndServe()
+}
+
+// ListenAndServeTLS serves the API over HTTPS on the TCP network address addr, see http.Server.ListenAndServeTLS.
+func (a *API) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	return a.newServer(addr).ListenAndServeTLS(certFile, keyFile)
+}
+
+// newServer returns the server configured with SetServerConfig, or a server with the default timeouts.
+func (a *API) newServer(addr string) *http.Server {
+	server := a.server
+	if server == nil {
+		server = &http.Server{
+			ReadTimeout:  DefaultReadTimeout,
+			WriteTimeout: DefaultWriteTimeout,
+			IdleTimeout:  DefaultIdleTimeout,
+		}
+	}
+
+	server.Addr = addr
+	server.Handler = a
+	return server
+}
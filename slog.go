@@ -0,0 +1,70 @@
+package jshapi
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+/*
+DefaultWithSlog builds a new top-level API like Default, logging to a slog.Logger:
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	api := jshapi.DefaultWithSlog("<prefix>", false, logger)
+
+Messages are logged at the info level, except Fatal and Panic ones logged at the error level.
+*/
+func DefaultWithSlog(prefix string, debug bool, logger *slog.Logger) *API {
+	return Default(prefix, debug, &slogLogger{logger: logger})
+}
+
+// slogLogger adapts a slog.Logger to the std.Logger interface.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Print(v ...interface{}) {
+	l.logger.Info(fmt.Sprint(v...))
+}
+
+func (l *slogLogger) Printf(format string, v ...interface{}) {
+	l.logger.Info(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+
+func (l *slogLogger) Println(v ...interface{}) {
+	l.logger.Info(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (l *slogLogger) Fatal(v ...interface{}) {
+	l.logger.Error(fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+func (l *slogLogger) Fatalf(format string, v ...interface{}) {
+	l.logger.Error(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+	os.Exit(1)
+}
+
+func (l *slogLogger) Fatalln(v ...interface{}) {
+	l.logger.Error(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	os.Exit(1)
+}
+
+func (l *slogLogger) Panic(v ...interface{}) {
+	message := fmt.Sprint(v...)
+	l.logger.Error(message)
+	panic(message)
+}
+
+func (l *slogLogger) Panicf(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	l.logger.Error(strings.TrimSuffix(message, "\n"))
+	panic(message)
+}
+
+func (l *slogLogger) Panicln(v ...interface{}) {
+	message := fmt.Sprintln(v...)
+	l.logger.Error(strings.TrimSuffix(message, "\n"))
+	panic(message)
+}
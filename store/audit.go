@@ -0,0 +1,32 @@
+package store
+
+import (
+	"golang.org/x/net/context"
+)
+
+// CloudEventsSpecVersion is the version of the CloudEvents specification events conform to.
+const CloudEventsSpecVersion = "1.0"
+
+// Audit event types emitted after each mutation.
+const (
+	EventSaved   = "com.jshapi.resource.saved"
+	EventUpdated = "com.jshapi.resource.updated"
+	EventDeleted = "com.jshapi.resource.deleted"
+)
+
+// CloudEvent is an audit event formatted as per the CloudEvents specification:
+// https://github.com/cloudevents/spec
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+}
+
+// CloudEventAuditor can be implemented by a CRUD storage to receive an audit event
+// after each successful save, update and delete.
+type CloudEventAuditor interface {
+	Emit(ctx context.Context, event CloudEvent) error
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+// Cache stores the objects and lists read from storage by key.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, ttl time.Duration)
+	// DeletePrefix removes the entries whose key starts with prefix.
+	DeletePrefix(prefix string)
+}
+
+// InMemoryCache is a Cache keeping values in memory.
+// It is safe for concurrent use.
+type InMemoryCache struct {
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached value with its expiration time.
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// NewInMemoryCache creates an empty in-memory cache.
+func NewInMemoryCache() *InMemoryCache {
+	return &InMemoryCache{entries: map[string]cacheEntry{}}
+}
+
+// Get returns the value cached for the key, if it has not expired.
+func (c *InMemoryCache) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set caches the value for the key during ttl.
+func (c *InMemoryCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]cacheEntry{}
+	}
+	c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// DeletePrefix removes the entries whose key starts with prefix.
+func (c *InMemoryCache) DeletePrefix(prefix string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+/*
+CachedCRUD is a read-through cache decorator for the Get and List storage of a resource
+type. Successful results are cached during TTL under the "<type>:get:<id>" and
+"<type>:list:<key>" keys, and Invalidate removes all the entries of the type.
+
+Cached objects are copied before being returned, so that callers adding links or meta
+do not alter the cache.
+*/
+type CachedCRUD struct {
+	Type  string
+	Cache Cache
+	TTL   time.Duration
+}
+
+// Get decorates the storage with the cache.
+func (c *CachedCRUD) Get(storage Get) Get {
+	return func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		key := c.Type + ":get:" + id
+		if cached, hit := c.Cache.Get(key); hit {
+			return copyObject(cached.(*jsh.Object)), nil
+		}
+
+		object, err := storage(ctx, id)
+		if object != nil && (err == nil || reflect.ValueOf(err).IsNil()) {
+			c.Cache.Set(key, copyObject(object), c.TTL)
+		}
+		return object, err
+	}
+}
+
+// List decorates the storage with the cache, key identifying the list among the
+// lists of the type, such as its encoded query parameters.
+func (c *CachedCRUD) List(key string, storage List) List {
+	return func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+		key := c.Type + ":list:" + key
+		if cached, hit := c.Cache.Get(key); hit {
+			return copyList(cached.(jsh.List)), nil
+		}
+
+		list, err := storage(ctx)
+		if list != nil && (err == nil || reflect.ValueOf(err).IsNil()) {
+			c.Cache.Set(key, copyList(list), c.TTL)
+		}
+		return list, err
+	}
+}
+
+// Invalidate removes all the cached entries of the type.
+func (c *CachedCRUD) Invalidate() {
+	c.Cache.DeletePrefix(c.Type + ":")
+}
+
+// copyObject copies the object along with its links, relationships and meta.
+func copyObject(object *jsh.Object) *jsh.Object {
+	copied := *object
+	if object.Links != nil {
+		copied.Links = make(map[string]*jsh.Link, len(object.Links))
+		for name, link := range object.Links {
+			copied.Links[name] = link
+		}
+	}
+	if object.Relationships != nil {
+		copied.Relationships = make(map[string]*jsh.Relationship, len(object.Relationships))
+		for name, relationship := range object.Relationships {
+			if relationship != nil {
+				copiedRelationship := *relationship
+				relationship = &copiedRelationship
+			}
+			copied.Relationships[name] = relationship
+		}
+	}
+	if object.Meta != nil {
+		copied.Meta = make(map[string]interface{}, len(object.Meta))
+		for key, value := range object.Meta {
+			copied.Meta[key] = value
+		}
+	}
+	return &copied
+}
+
+// copyList copies each object of the list.
+func copyList(list jsh.List) jsh.List {
+	copied := make(jsh.List, 0, len(list))
+	for _, object := range list {
+		copied = append(copied, copyObject(object))
+	}
+	return copied
+}
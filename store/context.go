@@ -0,0 +1,99 @@
+package store
+
+import (
+	"golang.org/x/net/context"
+)
+
+// contextKey is the type of the context keys defined by store.
+type contextKey int
+
+const (
+	// ProjectionHintKey is the context key holding the ProjectionHint of a request
+	ProjectionHintKey contextKey = iota
+	// TypedFiltersKey is the context key holding the TypedFilterMap of a request
+	TypedFiltersKey
+	// RequestMetaKey is the context key holding the RequestMeta of a request
+	RequestMetaKey
+	// IDComponentsKey is the context key holding the IDComponents of a request
+	IDComponentsKey
+	// ToManyOperationKey is the context key holding the ToManyOperation of a request
+	ToManyOperationKey
+	// SearchAfterKey is the context key holding the SearchAfterCursor of a request
+	SearchAfterKey
+)
+
+/*
+ProjectionHint lists the attributes a request needs, storage drivers supporting column
+selection can use it to avoid fetching the others. It is derived from the sparse
+fieldset of the request, `fields[<type>]=<attribute>,<attribute>`, and is not set
+when all attributes are needed.
+*/
+type ProjectionHint []string
+
+// ProjectionHintFromContext returns the projection hint of the request, if any.
+func ProjectionHintFromContext(ctx context.Context) (ProjectionHint, bool) {
+	hint, ok := ctx.Value(ProjectionHintKey).(ProjectionHint)
+	return hint, ok
+}
+
+// TypedFiltersFromContext returns the typed filters of the request, if any.
+func TypedFiltersFromContext(ctx context.Context) (TypedFilterMap, bool) {
+	filters, ok := ctx.Value(TypedFiltersKey).(TypedFilterMap)
+	return filters, ok
+}
+
+/*
+RequestMeta holds storage hints sent by the client as a flat JSON object in the
+`X-Request-Meta` header, such as a preferred replica, a read consistency level or
+a cache bypass flag. It is empty when the header is not set.
+*/
+type RequestMeta map[string]interface{}
+
+// RequestMetaFromContext returns the request meta of the request, if any.
+func RequestMetaFromContext(ctx context.Context) (RequestMeta, bool) {
+	meta, ok := ctx.Value(RequestMetaKey).(RequestMeta)
+	return meta, ok
+}
+
+// IDComponents holds the components of a composite resource ID, keyed by name.
+type IDComponents map[string]string
+
+// IDComponentsFromContext returns the components of the resource ID of the request, if any.
+func IDComponentsFromContext(ctx context.Context) (IDComponents, bool) {
+	components, ok := ctx.Value(IDComponentsKey).(IDComponents)
+	return components, ok
+}
+
+// ToManyOperation is the operation requested on a to-many relationship.
+type ToManyOperation string
+
+// Operations of the POST, PATCH and DELETE to-many relationship routes.
+const (
+	ToManyAdd     ToManyOperation = "add"
+	ToManyReplace ToManyOperation = "replace"
+	ToManyRemove  ToManyOperation = "remove"
+)
+
+// ToManyOperationFromContext returns the operation requested on a to-many relationship,
+// or an empty operation outside of to-many relationship updates.
+func ToManyOperationFromContext(ctx context.Context) ToManyOperation {
+	operation, _ := ctx.Value(ToManyOperationKey).(ToManyOperation)
+	return operation
+}
+
+/*
+SearchAfterCursor is the position of a keyset paginated list, decoded from the
+`page[after]=<cursor>` query parameter. Storage drivers should return the objects
+following it, typically with a `WHERE (field, id) > (last_field, last_id)` clause.
+LastField is empty when the list is not sorted.
+*/
+type SearchAfterCursor struct {
+	LastID    string `json:"last_id"`
+	LastField string `json:"last_field,omitempty"`
+}
+
+// SearchAfterCursorFromContext returns the search-after cursor of the request, if any.
+func SearchAfterCursorFromContext(ctx context.Context) (*SearchAfterCursor, bool) {
+	cursor, ok := ctx.Value(SearchAfterKey).(*SearchAfterCursor)
+	return cursor, ok
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+// CursorPage holds the cursors of the pages surrounding a page of a cursor paginated list.
+// T is the cursor type of the application, e.g. `struct{ID string; UpdatedAt time.Time}`.
+// A nil cursor means there is no such page.
+type CursorPage[T any] struct {
+	Next *T
+	Prev *T
+}
+
+// CursorEncoder encodes cursors to the opaque strings sent to clients, and decodes them back.
+type CursorEncoder[T any] interface {
+	Encode(cursor T) (string, error)
+	Decode(value string) (T, error)
+}
+
+// Base64JSONEncoder is a CursorEncoder encoding cursors as base64 URL encoded JSON.
+type Base64JSONEncoder[T any] struct{}
+
+// Encode implements CursorEncoder.
+func (Base64JSONEncoder[T]) Encode(cursor T) (string, error) {
+	content, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+// Decode implements CursorEncoder.
+func (Base64JSONEncoder[T]) Decode(value string) (T, error) {
+	var cursor T
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(content, &cursor)
+	return cursor, err
+}
+
+// CursorList lists a page of instances of a resource from storage, following the after cursor
+// or preceding the before cursor, if any, and returns the cursors of the surrounding pages.
+type CursorList[T any] func(ctx context.Context, after, before *T) (jsh.List, CursorPage[T], jsh.ErrorType)
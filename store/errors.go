@@ -0,0 +1,36 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+// SQLErrorMapper converts an error returned by a storage, possibly wrapping a database/sql
+// error, to the error sent to the client. It returns nil to send the error unchanged.
+type SQLErrorMapper func(err error) jsh.ErrorType
+
+// DefaultSQLErrorMapper maps errors wrapping sql.ErrNoRows to 404 errors, sql.ErrTxDone to
+// 500 errors and context.DeadlineExceeded to 503 errors.
+func DefaultSQLErrorMapper(err error) jsh.ErrorType {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &jsh.Error{
+			Title:  "Not Found",
+			Detail: "The requested resource does not exist",
+			Status: http.StatusNotFound,
+		}
+	case errors.Is(err, sql.ErrTxDone):
+		return jsh.ISE(err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return &jsh.Error{
+			Title:  "Service Unavailable",
+			Detail: "The storage did not respond in time",
+			Status: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FilterValue is a filter value parsed according to its expected type: string, int,
+// bool or time.Time. Filters with several values hold a []FilterValue.
+type FilterValue interface{}
+
+// TypedFilterMap holds the parsed values of the `filter[<field>]` query parameters, keyed by field.
+type TypedFilterMap map[string]FilterValue
+
+/*
+ParseFilterValue parses a raw filter value given its expected type:
+
+	string: the raw value
+	int:    an int
+	bool:   a bool, see strconv.ParseBool
+	time:   a time.Time formatted as RFC 3339
+*/
+func ParseFilterValue(raw, expectedType string) (FilterValue, error) {
+	switch expectedType {
+	case "string":
+		return raw, nil
+	case "int":
+		return strconv.Atoi(raw)
+	case "bool":
+		return strconv.ParseBool(raw)
+	case "time":
+		return time.Parse(time.RFC3339, raw)
+	default:
+		return nil, fmt.Errorf("unsupported filter type '%s'", expectedType)
+	}
+}
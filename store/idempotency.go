@@ -0,0 +1,68 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// IdempotencyCache stores action responses by idempotency key.
+type IdempotencyCache interface {
+	Get(key string) (*jsh.Object, bool)
+	Set(key string, object *jsh.Object, ttl time.Duration)
+}
+
+// InMemoryIdempotencyCache is an IdempotencyCache keeping responses in memory.
+// It is safe for concurrent use.
+type InMemoryIdempotencyCache struct {
+	// TTL is used when Set is called with a zero ttl
+	TTL     time.Duration
+	mutex   sync.Mutex
+	entries map[string]idempotencyEntry
+}
+
+// idempotencyEntry is a cached response with its expiration time.
+type idempotencyEntry struct {
+	object  *jsh.Object
+	expires time.Time
+}
+
+// NewInMemoryIdempotencyCache creates an in-memory cache keeping responses for ttl by default.
+func NewInMemoryIdempotencyCache(ttl time.Duration) *InMemoryIdempotencyCache {
+	return &InMemoryIdempotencyCache{
+		TTL:     ttl,
+		entries: map[string]idempotencyEntry{},
+	}
+}
+
+// Get returns the response cached for the key, if it has not expired.
+func (c *InMemoryIdempotencyCache) Get(key string) (*jsh.Object, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.object, true
+}
+
+// Set caches the response for the key during ttl, or the cache TTL if ttl is zero.
+func (c *InMemoryIdempotencyCache) Set(key string, object *jsh.Object, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = c.TTL
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]idempotencyEntry{}
+	}
+	c.entries[key] = idempotencyEntry{object: object, expires: time.Now().Add(ttl)}
+}
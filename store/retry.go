@@ -0,0 +1,114 @@
+package store
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+// RetryPolicy defines how a storage operation is retried on failure.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int
+	// Backoff returns the delay to wait before the given attempt, starting from 1
+	Backoff func(attempt int) time.Duration
+	// IsTransient reports whether the error is worth retrying
+	IsTransient func(err jsh.ErrorType) bool
+}
+
+// DefaultRetryPolicy retries an operation 3 times with an exponential backoff
+// starting at 100ms when storage returns a 503 error.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: func(attempt int) time.Duration {
+			return 100 * time.Millisecond << uint(attempt-1)
+		},
+		IsTransient: func(err jsh.ErrorType) bool {
+			return err.StatusCode() == http.StatusServiceUnavailable
+		},
+	}
+}
+
+// WithRetry wraps a CRUD storage so that all operations are retried according
+// to the given policy. Retries stop as soon as the context is done.
+func WithRetry(inner CRUD, policy RetryPolicy) CRUD {
+	return &retryCRUD{inner: inner, policy: policy}
+}
+
+// retryCRUD is a CRUD decorator retrying transient errors.
+type retryCRUD struct {
+	inner  CRUD
+	policy RetryPolicy
+}
+
+// Save implements CRUD.
+func (s *retryCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	var result *jsh.Object
+	err := s.retry(ctx, func() (err jsh.ErrorType) {
+		result, err = s.inner.Save(ctx, object)
+		return err
+	})
+	return result, err
+}
+
+// Get implements CRUD.
+func (s *retryCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	var result *jsh.Object
+	err := s.retry(ctx, func() (err jsh.ErrorType) {
+		result, err = s.inner.Get(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+// List implements CRUD.
+func (s *retryCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	var result jsh.List
+	err := s.retry(ctx, func() (err jsh.ErrorType) {
+		result, err = s.inner.List(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Update implements CRUD.
+func (s *retryCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	var result *jsh.Object
+	err := s.retry(ctx, func() (err jsh.ErrorType) {
+		result, err = s.inner.Update(ctx, object)
+		return err
+	})
+	return result, err
+}
+
+// Delete implements CRUD.
+func (s *retryCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return s.retry(ctx, func() jsh.ErrorType {
+		return s.inner.Delete(ctx, id)
+	})
+}
+
+// retry calls the operation until it succeeds, fails with a non transient error,
+// runs out of attempts or the context is done. The last error is returned.
+func (s *retryCRUD) retry(ctx context.Context, operation func() jsh.ErrorType) jsh.ErrorType {
+	var err jsh.ErrorType
+	for attempt := 1; ; attempt++ {
+		err = operation()
+		if err == nil || reflect.ValueOf(err).IsNil() {
+			return nil
+		}
+		if attempt >= s.policy.MaxAttempts || !s.policy.IsTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(s.policy.Backoff(attempt)):
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"golang.org/x/net/context"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flakyCRUD is a CRUD storage failing with the errors of its queue before succeeding.
+type flakyCRUD struct {
+	errors []jsh.ErrorType
+	calls  int
+}
+
+func (s *flakyCRUD) call() jsh.ErrorType {
+	s.calls++
+	if len(s.errors) == 0 {
+		return nil
+	}
+	err := s.errors[0]
+	s.errors = s.errors[1:]
+	return err
+}
+
+func (s *flakyCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return object, s.call()
+}
+
+func (s *flakyCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	return &jsh.Object{ID: id}, s.call()
+}
+
+func (s *flakyCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	return jsh.List{}, s.call()
+}
+
+func (s *flakyCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return object, s.call()
+}
+
+func (s *flakyCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return s.call()
+}
+
+func unavailable() jsh.ErrorType {
+	return &jsh.Error{Title: "Service Unavailable", Status: http.StatusServiceUnavailable}
+}
+
+func TestWithRetry(t *testing.T) {
+
+	Convey("WithRetry Tests", t, func() {
+		var backoffs []int
+		policy := DefaultRetryPolicy()
+		policy.Backoff = func(attempt int) time.Duration {
+			backoffs = append(backoffs, attempt)
+			return 0
+		}
+
+		Convey("should retry transient errors", func() {
+			tests := []struct {
+				name     string
+				errors   []jsh.ErrorType
+				calls    int
+				backoffs []int
+				status   int
+			}{
+				{"success", nil, 1, nil, 0},
+				{"one transient error", []jsh.ErrorType{unavailable()}, 2, []int{1}, 0},
+				{"two transient errors", []jsh.ErrorType{unavailable(), unavailable()}, 3, []int{1, 2}, 0},
+				{"out of attempts", []jsh.ErrorType{unavailable(), unavailable(), unavailable()}, 3, []int{1, 2}, http.StatusServiceUnavailable},
+				{"non transient error", []jsh.ErrorType{jsh.NotFound("users", "1")}, 1, nil, http.StatusNotFound},
+				{"non transient after transient", []jsh.ErrorType{unavailable(), jsh.ISE("failure")}, 2, []int{1}, http.StatusInternalServerError},
+			}
+
+			for _, test := range tests {
+				backoffs = nil
+				inner := &flakyCRUD{errors: test.errors}
+				object, err := WithRetry(inner, policy).Get(context.Background(), "1")
+
+				So(inner.calls, ShouldEqual, test.calls)
+				So(backoffs, ShouldResemble, test.backoffs)
+				if test.status == 0 {
+					So(err, ShouldBeNil)
+					So(object.ID, ShouldEqual, "1")
+				} else {
+					So(err, ShouldNotBeNil)
+					So(err.StatusCode(), ShouldEqual, test.status)
+				}
+			}
+		})
+
+		Convey("should retry every operation", func() {
+			inner := &flakyCRUD{}
+			storage := WithRetry(inner, policy)
+			ctx := context.Background()
+			operations := []func() jsh.ErrorType{
+				func() jsh.ErrorType { _, err := storage.Save(ctx, &jsh.Object{}); return err },
+				func() jsh.ErrorType { _, err := storage.Get(ctx, "1"); return err },
+				func() jsh.ErrorType { _, err := storage.List(ctx); return err },
+				func() jsh.ErrorType { _, err := storage.Update(ctx, &jsh.Object{}); return err },
+				func() jsh.ErrorType { return storage.Delete(ctx, "1") },
+			}
+
+			for _, operation := range operations {
+				inner.calls = 0
+				inner.errors = []jsh.ErrorType{unavailable()}
+				So(operation(), ShouldBeNil)
+				So(inner.calls, ShouldEqual, 2)
+			}
+		})
+
+		Convey("should stop retrying once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			policy.Backoff = func(attempt int) time.Duration { return time.Minute }
+			inner := &flakyCRUD{errors: []jsh.ErrorType{unavailable(), unavailable()}}
+
+			err := WithRetry(inner, policy).Delete(ctx, "1")
+			So(err, ShouldNotBeNil)
+			So(inner.calls, ShouldEqual, 1)
+		})
+
+		Convey("DefaultRetryPolicy() should back off exponentially", func() {
+			backoff := DefaultRetryPolicy().Backoff
+			So(backoff(1), ShouldEqual, 100*time.Millisecond)
+			So(backoff(2), ShouldEqual, 200*time.Millisecond)
+			So(backoff(3), ShouldEqual, 400*time.Millisecond)
+		})
+	})
+}
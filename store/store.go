@@ -18,24 +18,195 @@ type CRUD interface {
 	Delete(ctx context.Context, id string) jsh.ErrorType
 }
 
+// HealthChecker can be implemented by storage to report whether it is able to serve
+// requests, production storage implementations should implement it to take part in
+// health checks.
+type HealthChecker interface {
+	// Ping returns an error if the storage is unavailable
+	Ping(ctx context.Context) error
+}
+
 // Save a new resource to storage.
 type Save func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
 
+// AsyncSave starts the creation of a new resource in storage. It returns the URL of
+// the job creating the resource, or an empty string if it was created synchronously.
+type AsyncSave func(ctx context.Context, object *jsh.Object) (*jsh.Object, string, jsh.ErrorType)
+
+// DryRunSave saves a new resource to storage. When dryRun is true, it only validates
+// the object and returns it as it would be saved, without writing it.
+type DryRunSave func(ctx context.Context, object *jsh.Object, dryRun bool) (*jsh.Object, jsh.ErrorType)
+
 // Get a specific instance of a resource by id from storage.
 type Get func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType)
 
+// RandomGet fetches a random instance of a resource from storage.
+type RandomGet func(ctx context.Context) (*jsh.Object, jsh.ErrorType)
+
+// FieldGetter can be implemented by a CRUD storage to only fetch the fields requested by
+// a sparse fieldset, e.g. with a `SELECT id, a, b` query.
+type FieldGetter interface {
+	GetWithFields(ctx context.Context, id string, fields []string) (*jsh.Object, jsh.ErrorType)
+}
+
+// GetWithFields fetches a specific instance of a resource by id from storage,
+// with only the given fields.
+type GetWithFields func(ctx context.Context, id string, fields []string) (*jsh.Object, jsh.ErrorType)
+
 // List all instances of a resource from storage.
 type List func(ctx context.Context) (jsh.List, jsh.ErrorType)
 
+// Pagination holds the `page[number]`, `page[size]`, `page[offset]` and `page[limit]`
+// query parameters of a paginated list request, a parameter that is not set being zero.
+type Pagination struct {
+	Number int
+	Size   int
+	Offset int
+	Limit  int
+}
+
+// PageResult is a page of a paginated list along with the total number of instances of
+// the list, or -1 if it is unknown.
+type PageResult struct {
+	List  jsh.List
+	Total int
+}
+
+// PagedList lists a page of the instances of a resource from storage.
+type PagedList func(ctx context.Context, p Pagination) (jsh.List, jsh.ErrorType)
+
+// CountedPagedList lists a page of the instances of a resource from storage, along with
+// the total number of instances.
+type CountedPagedList func(ctx context.Context, p Pagination) (PageResult, jsh.ErrorType)
+
+// Counter counts the instances of a resource in storage.
+type Counter interface {
+	Count(ctx context.Context) (int, jsh.ErrorType)
+}
+
+// ListWithRelationships lists all instances of a resource from storage along with their
+// related objects, keyed by relationship name.
+type ListWithRelationships func(ctx context.Context) (jsh.List, map[string]jsh.List, jsh.ErrorType)
+
+// FilterMap holds the values of the `filter[<field>]` query parameters, keyed by field.
+type FilterMap map[string][]string
+
+// SortField is a field of the `sort` query parameter, descending if it is "-" prefixed.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// QueryParams holds the `filter[<field>]=<value>` and `sort=<field>,-<field>` query
+// parameters of a list request.
+type QueryParams struct {
+	Filters map[string]string
+	Sort    []SortField
+}
+
+// FilteredList lists the instances of a resource in storage matching the filters of the
+// query parameters, in their sort order.
+type FilteredList func(ctx context.Context, params QueryParams) (jsh.List, jsh.ErrorType)
+
+// ChunkedList lists the instances of a resource in storage a chunk at a time, so that they
+// can be streamed to the client. NextChunk returns up to chunkSize objects and whether more
+// chunks are available.
+type ChunkedList interface {
+	NextChunk(ctx context.Context, chunkSize int) (jsh.List, bool, jsh.ErrorType)
+}
+
+// Search for instances of a resource in storage matching a query and filters.
+type Search func(ctx context.Context, query string, filters FilterMap) (jsh.List, jsh.ErrorType)
+
 // Update an existing object in storage.
+// Implementations should return the full object when the update changed fields that
+// were not part of the request, such as computed fields, so that it is sent back with
+// a 200 response. They should return nil when the client already has the current state
+// of the object, in which case a 204 response is sent.
 type Update func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
 
+// DiffUpdater can be implemented by a CRUD storage to receive the current state of
+// the object along with the patched object, e.g. to compute a diff for audit logs,
+// event sourcing or optimistic lock checks.
+type DiffUpdater interface {
+	DiffUpdate(ctx context.Context, old, new *jsh.Object) (*jsh.Object, jsh.ErrorType)
+}
+
+// DiffUpdate an existing object in storage given its current state, fetched before the update.
+// The returned object follows the same rules as Update.
+type DiffUpdate func(ctx context.Context, old, new *jsh.Object) (*jsh.Object, jsh.ErrorType)
+
+// TrackingUpdater can be implemented by a CRUD storage to report which fields an update changed.
+type TrackingUpdater interface {
+	TrackingUpdate(ctx context.Context, object *jsh.Object) (*jsh.Object, []string, jsh.ErrorType)
+}
+
+// TrackingUpdate an existing object in storage and return the names of the changed fields.
+// The returned object follows the same rules as Update.
+type TrackingUpdate func(ctx context.Context, object *jsh.Object) (*jsh.Object, []string, jsh.ErrorType)
+
 // Delete an object from storage by id.
 type Delete func(ctx context.Context, id string) jsh.ErrorType
 
+// Archiver is a resource controller interface for two-stage deletion workflows,
+// where objects are archived before being deleted.
+type Archiver interface {
+	Archive(ctx context.Context, id string) jsh.ErrorType
+	Unarchive(ctx context.Context, id string) jsh.ErrorType
+	ListArchived(ctx context.Context) (jsh.List, jsh.ErrorType)
+}
+
+// IdempotentDelete deletes an object from storage by id and reports whether it existed.
+type IdempotentDelete func(ctx context.Context, id string) (bool, jsh.ErrorType)
+
+// BulkDelete deletes several objects from storage by id.
+// Partial failures should be reported with a BulkDeleteError.
+type BulkDelete func(ctx context.Context, ids []string) jsh.ErrorType
+
+// BulkDeleteError is returned by BulkDelete when some of the objects could not be deleted.
+// The failed IDs are sent in the meta of the error response, as "failed_ids".
+type BulkDeleteError struct {
+	// Cause is the error sent as response
+	Cause     *jsh.Error
+	FailedIDs []string
+}
+
+// Error implements jsh.ErrorType.
+func (e *BulkDeleteError) Error() string {
+	return e.Cause.Error()
+}
+
+// Validate implements jsh.ErrorType.
+func (e *BulkDeleteError) Validate(r *http.Request, response bool) *jsh.Error {
+	return e.Cause.Validate(r, response)
+}
+
+// StatusCode implements jsh.ErrorType.
+func (e *BulkDeleteError) StatusCode() int {
+	return e.Cause.StatusCode()
+}
+
+// DeleteWithReason deletes an object from storage by id, providing the reason of the deletion.
+type DeleteWithReason func(ctx context.Context, id, reason string) jsh.ErrorType
+
 // Action is a handler that performs a specific action on a resource.
 type Action func(ctx context.Context, w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType)
 
+// ListAction is a handler that performs a specific action on a resource, producing a list of objects.
+type ListAction func(ctx context.Context, w http.ResponseWriter, r *http.Request) (jsh.List, jsh.ErrorType)
+
+// Command is a handler that performs a specific action on a resource without request body.
+type Command func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType)
+
+// StreamingAction is a handler that performs a long-running action on a resource,
+// writing newline-delimited JSON progress updates directly to the response writer.
+type StreamingAction func(ctx context.Context, id string, w http.ResponseWriter, r *http.Request) jsh.ErrorType
+
+// RelationshipAction is a handler that performs a specific action on a resource
+// and a list of related resources parsed from the request body.
+type RelationshipAction func(ctx context.Context, id string, list jsh.IDList,
+	w http.ResponseWriter, r *http.Request) (*jsh.Object, jsh.ErrorType)
+
 // ToOne is a to-one resource relationship controller interface.
 type ToOne interface {
 	GetResource(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType)
@@ -47,6 +218,7 @@ type ToOne interface {
 type ToOneGet func(ctx context.Context, id string) (*jsh.IDObject, jsh.ErrorType)
 
 // Update an existing relationship in storage.
+// The relationship is nil when the request sets it to null with `{"data": null}`.
 type ToOneUpdate func(ctx context.Context, id string, relationship *jsh.IDObject) (*jsh.IDObject, jsh.ErrorType)
 
 // ToMany is a to-many resource relationship controller interface.
@@ -61,8 +233,33 @@ type ToMany interface {
 // List all resources related to a resource from storage.
 type ToManyListResources func(ctx context.Context, id string) (jsh.List, jsh.ErrorType)
 
+// DynamicToMany lists the resources related to a resource from storage, given the value
+// of the parameter segment of the relationship route.
+type DynamicToMany func(ctx context.Context, parentID, relParam string) (jsh.List, jsh.ErrorType)
+
 // List all relationships of a resource from storage.
 type ToManyList func(ctx context.Context, id string) (jsh.IDList, jsh.ErrorType)
 
+// ToManyCreator can be implemented by a ToMany storage to report whether adding
+// relationships created them, in which case a 201 response is sent instead of 200.
+type ToManyCreator interface {
+	Create(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, bool, jsh.ErrorType)
+}
+
+// ToManyCreate adds relationships in storage and reports whether they were all created.
+type ToManyCreate func(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, bool, jsh.ErrorType)
+
+// ToManyReplacer can be implemented by a ToMany storage to replace the full set of
+// relationships atomically, in which case PATCH requests call Replace instead of Update.
+type ToManyReplacer interface {
+	Replace(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType)
+}
+
+// Linker associates existing resources to a resource in storage, for `LINK /resources/:id` requests.
+type Linker func(ctx context.Context, id string, targets jsh.IDList) jsh.ErrorType
+
+// Unlinker dissociates resources from a resource in storage, for `UNLINK /resources/:id` requests.
+type Unlinker func(ctx context.Context, id string, targets jsh.IDList) jsh.ErrorType
+
 // Update existing relationships in storage.
 type ToManyUpdate func(ctx context.Context, id string, list jsh.IDList) (jsh.IDList, jsh.ErrorType)
@@ -0,0 +1,127 @@
+// Package testing provides helpers to build mock jshapi resources in tests, without having
+// to configure a jshapi.MockStorage by hand:
+//
+//	resource := (&testing.ResourceFixture{ResourceType: "users"}).
+//		WithObject("1", map[string]interface{}{"name": "Alice"}).
+//		WithError("DELETE", http.StatusForbidden, "Users cannot be deleted").
+//		Build()
+package testing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/jsh-api"
+	"golang.org/x/net/context"
+)
+
+// ResourceFixture builds a resource backed by a mock storage holding a fixed list of objects.
+type ResourceFixture struct {
+	// ResourceType is the type of the resource and of its objects
+	ResourceType string
+	// objects is the fixed list of the mock storage
+	objects jsh.List
+	// errors maps HTTP methods to the error sent by the storage handling them
+	errors map[string]*jsh.Error
+}
+
+// WithObject adds an object with the given ID and attributes to the fixture.
+func (f *ResourceFixture) WithObject(id string, attrs map[string]interface{}) *ResourceFixture {
+	object, err := jsh.NewObject(id, f.ResourceType, attrs)
+	if err != nil {
+		panic(fmt.Sprintf("jshapi/testing: invalid attributes for object %s: %s", id, err.Error()))
+	}
+	return f.WithList(object)
+}
+
+// WithList adds objects to the fixture, they are listed in the order they were added.
+func (f *ResourceFixture) WithList(objects ...*jsh.Object) *ResourceFixture {
+	f.objects = append(f.objects, objects...)
+	return f
+}
+
+// WithError makes the storage fail the requests of the given HTTP method, "GET" covering
+// both fetch and list requests, with an error of the given status and detail.
+func (f *ResourceFixture) WithError(method string, status int, detail string) *ResourceFixture {
+	if f.errors == nil {
+		f.errors = map[string]*jsh.Error{}
+	}
+	f.errors[strings.ToUpper(method)] = &jsh.Error{
+		Title:  http.StatusText(status),
+		Detail: detail,
+		Status: status,
+	}
+	return f
+}
+
+// Build returns a CRUD resource backed by a jshapi.MockStorage holding the fixture objects.
+// Fetching an object that is not part of the fixture responds with a 404 error.
+func (f *ResourceFixture) Build() *jshapi.Resource {
+	objects := jsh.List{}
+	objects = append(objects, f.objects...)
+
+	storage := &fixtureStorage{
+		MockStorage: &jshapi.MockStorage{
+			ResourceType: f.ResourceType,
+			FixedList:    objects,
+		},
+		errors: f.errors,
+	}
+	return jshapi.NewCRUDResource(f.ResourceType, storage)
+}
+
+// fixtureStorage is a mock storage failing the methods configured with WithError.
+type fixtureStorage struct {
+	*jshapi.MockStorage
+	errors map[string]*jsh.Error
+}
+
+// err returns the error configured for the HTTP method, if any.
+func (s *fixtureStorage) err(method string) jsh.ErrorType {
+	if err, ok := s.errors[method]; ok {
+		return err
+	}
+	return nil
+}
+
+// Save fails if an error is configured for POST.
+func (s *fixtureStorage) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	if err := s.err("POST"); err != nil {
+		return nil, err
+	}
+	return s.MockStorage.Save(ctx, object)
+}
+
+// Get fails if an error is configured for GET.
+func (s *fixtureStorage) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	if err := s.err("GET"); err != nil {
+		return nil, err
+	}
+	return s.MockStorage.Get(ctx, id)
+}
+
+// List fails if an error is configured for GET.
+func (s *fixtureStorage) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	if err := s.err("GET"); err != nil {
+		return nil, err
+	}
+	return s.MockStorage.List(ctx)
+}
+
+// Update fails if an error is configured for PATCH.
+func (s *fixtureStorage) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	if err := s.err("PATCH"); err != nil {
+		return nil, err
+	}
+	return s.MockStorage.Update(ctx, object)
+}
+
+// Delete fails if an error is configured for DELETE.
+func (s *fixtureStorage) Delete(ctx context.Context, id string) jsh.ErrorType {
+	if err := s.err("DELETE"); err != nil {
+		return err
+	}
+	return s.MockStorage.Delete(ctx, id)
+}
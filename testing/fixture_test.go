@@ -0,0 +1,104 @@
+package testing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EtixLabs/go-json-spec-handler"
+	"github.com/EtixLabs/go-json-spec-handler/client"
+	"github.com/EtixLabs/jsh-api"
+	jshtesting "github.com/EtixLabs/jsh-api/testing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResourceFixture(t *testing.T) {
+
+	Convey("ResourceFixture Tests", t, func() {
+		fixture := (&jshtesting.ResourceFixture{ResourceType: "users"}).
+			WithObject("2", map[string]interface{}{"name": "Bob"}).
+			WithObject("1", map[string]interface{}{"name": "Alice"})
+		serve := func() string {
+			api := jshapi.New("")
+			api.Add(fixture.Build())
+			server := httptest.NewServer(api)
+			Reset(server.Close)
+			return server.URL
+		}
+
+		Convey("should list the objects in the order they were added", func() {
+			fixture.WithList(&jsh.Object{ID: "3", Type: "users"})
+			doc, resp, err := jsc.List(serve(), "users")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(len(doc.Data), ShouldEqual, 3)
+			So(doc.Data[0].ID, ShouldEqual, "2")
+			So(doc.Data[1].ID, ShouldEqual, "1")
+			So(doc.Data[2].ID, ShouldEqual, "3")
+		})
+
+		Convey("should fetch the objects of the fixture", func() {
+			doc, resp, err := jsc.Fetch(serve(), "users", "1")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(string(doc.Data[0].Attributes), ShouldContainSubstring, "Alice")
+		})
+
+		Convey("should respond 404 for unknown IDs", func() {
+			_, resp, err := jsc.Fetch(serve(), "users", "42")
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->WithError()", func() {
+
+			Convey("should fail fetch and list requests for GET", func() {
+				fixture.WithError("get", http.StatusServiceUnavailable, "Down for maintenance")
+				baseURL := serve()
+
+				doc, resp, err := jsc.Fetch(baseURL, "users", "1")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+				So(doc.Errors[0].Detail, ShouldEqual, "Down for maintenance")
+
+				_, resp, err = jsc.List(baseURL, "users")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			})
+
+			Convey("should only fail the requests of the method", func() {
+				fixture.WithError("DELETE", http.StatusForbidden, "Users cannot be deleted")
+				baseURL := serve()
+
+				resp, err := jsc.Delete(baseURL, "users", "1")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+
+				_, resp, err = jsc.Post(baseURL, &jsh.Object{Type: "users"})
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+
+				_, resp, err = jsc.Patch(baseURL, &jsh.Object{ID: "1", Type: "users"})
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should map POST and PATCH to creation and update", func() {
+				fixture.WithError("POST", http.StatusConflict, "Duplicate user").
+					WithError("PATCH", http.StatusPreconditionFailed, "Stale user")
+				baseURL := serve()
+
+				_, resp, err := jsc.Post(baseURL, &jsh.Object{Type: "users"})
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+
+				_, resp, err = jsc.Patch(baseURL, &jsh.Object{ID: "1", Type: "users"})
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusPreconditionFailed)
+			})
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package jshapi
+
+import (
+	"github.com/EtixLabs/go-json-spec-handler"
+)
+
+// SetTestData sets the objects served by the resource once the API is in test mode.
+func (res *Resource) SetTestData(objects []*jsh.Object) {
+	res.testData = objects
+}
+
+/*
+EnableTestMode replaces the storage of every resource of the API with a MockStorage
+serving the test data of the resource, so that integration tests can run a fully
+functional API without any external dependency:
+
+	resource.SetTestData([]*jsh.Object{user})
+	api := jshapi.New("").EnableTestMode()
+
+All the routes and middleware registered to the resources are replaced by the
+default CRUD routes.
+*/
+func (a *API) EnableTestMode() *API {
+	seen := map[*Resource]bool{}
+	for _, resource := range a.Resources {
+		if seen[resource] {
+			continue
+		}
+		seen[resource] = true
+
+		testData := jsh.List(resource.testData)
+		if testData == nil {
+			testData = jsh.List{}
+		}
+
+		resource.reset()
+		resource.CRUD(&MockStorage{
+			ResourceType: resource.Type,
+			FixedList:    testData,
+		})
+	}
+	return a
+}
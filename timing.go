@@ -0,0 +1,123 @@
+package jshapi
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// timingBucketCount is the number of histogram buckets, the upper bound of bucket i
+// is 2^i microseconds, the last bucket holding any longer duration.
+const timingBucketCount = 32
+
+// timingBucket accumulates the storage call durations of a route.
+type timingBucket struct {
+	Count     int64
+	TotalNs   int64
+	Histogram [timingBucketCount]int64
+}
+
+// TimingStats summarizes the storage call durations of a route.
+type TimingStats struct {
+	Count int64
+	Mean  time.Duration
+	// P95 is the upper bound of the histogram bucket containing the 95th percentile
+	P95 time.Duration
+}
+
+/*
+EnableTiming starts recording the duration of the storage calls made by each route
+of the resource. Use TimingReport to retrieve the statistics:
+
+	resource.EnableTiming()
+	for route, stats := range resource.TimingReport() {
+		log.Printf("%s: %d calls, mean %s, p95 %s", route, stats.Count, stats.Mean, stats.P95)
+	}
+*/
+func (res *Resource) EnableTiming() {
+	if res.timings == nil {
+		res.timings = &sync.Map{}
+	}
+}
+
+// TimingReport returns the timing statistics of each route of the resource called
+// since timing was enabled, keyed by "<METHOD> <path>".
+func (res *Resource) TimingReport() map[string]TimingStats {
+	report := map[string]TimingStats{}
+	if res.timings == nil {
+		return report
+	}
+
+	res.timings.Range(func(key, value interface{}) bool {
+		report[key.(string)] = value.(*timingBucket).stats()
+		return true
+	})
+	return report
+}
+
+// TimingReport aggregates the timing reports of all the resources of the API.
+func (a *API) TimingReport() map[string]TimingStats {
+	report := map[string]TimingStats{}
+	for _, resource := range a.Resources {
+		for route, stats := range resource.TimingReport() {
+			report[route] = stats
+		}
+	}
+	return report
+}
+
+// recordTiming records the time elapsed since start for the route matching the request.
+func (res *Resource) recordTiming(ctx context.Context, r *http.Request, start time.Time) {
+	if res.timings == nil {
+		return
+	}
+	elapsed := time.Since(start)
+
+	value, _ := res.timings.LoadOrStore(res.routeKey(ctx, r), &timingBucket{})
+	value.(*timingBucket).record(elapsed)
+}
+
+// record adds a duration to the bucket.
+func (b *timingBucket) record(elapsed time.Duration) {
+	atomic.AddInt64(&b.Count, 1)
+	atomic.AddInt64(&b.TotalNs, int64(elapsed))
+
+	i := 0
+	for i < timingBucketCount-1 && elapsed > timingBucketBound(i) {
+		i++
+	}
+	atomic.AddInt64(&b.Histogram[i], 1)
+}
+
+// stats computes the mean and 95th percentile of the bucket.
+func (b *timingBucket) stats() TimingStats {
+	count := atomic.LoadInt64(&b.Count)
+	if count == 0 {
+		return TimingStats{}
+	}
+
+	stats := TimingStats{
+		Count: count,
+		Mean:  time.Duration(atomic.LoadInt64(&b.TotalNs) / count),
+	}
+
+	// rank of the 95th percentile, rounded up
+	rank := (count*95 + 99) / 100
+	var seen int64
+	for i := 0; i < timingBucketCount; i++ {
+		seen += atomic.LoadInt64(&b.Histogram[i])
+		if seen >= rank {
+			stats.P95 = timingBucketBound(i)
+			break
+		}
+	}
+	return stats
+}
+
+// timingBucketBound returns the upper bound of the histogram bucket i.
+func timingBucketBound(i int) time.Duration {
+	return time.Microsecond << uint(i)
+}
@@ -34,3 +34,15 @@ func sampleObject(id string, resourceType string, sampleObject interface{}) *jsh
 
 	return object
 }
+
+// ValidateIDMatch returns a 409 error if the ID of the object sent as body does not match
+// the ID of the URL. Custom actions parsing an object from the body should use it as well.
+func ValidateIDMatch(urlID string, object *jsh.Object) jsh.ErrorType {
+	if object == nil {
+		return jsh.BadRequestError("Invalid document", "Missing object")
+	}
+	if object.ID != urlID {
+		return jsh.ConflictError("", object.ID)
+	}
+	return nil
+}